@@ -10,24 +10,214 @@ import (
 
 // TerraformOutputsSpec defines the desired state of TerraformOutputs
 type TerraformOutputsSpec struct {
-	// Backends defines the list of backend configurations
+	// Backends defines the list of backend configurations, identified by their unique name
 	// +kubebuilder:validation:MinItems=1
+	// +listType=atomic
 	Backends []BackendSpec `json:"backends"`
 
 	// SyncInterval defines how often to sync outputs (default: 5m)
 	// +kubebuilder:default="5m"
 	SyncInterval string `json:"syncInterval,omitempty"`
 
+	// ChangeDetection defines how a changed backend state is detected. Version compares the
+	// object version reported by the backend (S3 ETag, GCS generation, ...). Serial and
+	// ContentHash also download the state when its version changes, and only sync when the
+	// state lineage and serial, or the state content, changed.
+	// +kubebuilder:validation:Enum=Version;Serial;ContentHash
+	// +kubebuilder:default="Version"
+	// +optional
+	ChangeDetection string `json:"changeDetection,omitempty"`
+
+	// MergeStrategy defines how an output exported by several backends is merged. lastWins
+	// and firstWins keep the value of the last or first backend, error fails the sync,
+	// prefixByBackend prefixes every output with its backend and deepMerge merges map outputs,
+	// the last backend winning for other values.
+	// +kubebuilder:validation:Enum=lastWins;firstWins;error;prefixByBackend;deepMerge
+	// +kubebuilder:default="lastWins"
+	// +optional
+	MergeStrategy string `json:"mergeStrategy,omitempty"`
+
+	// Outputs selects the merged outputs written to the targets
+	// +optional
+	Outputs *OutputsSpec `json:"outputs,omitempty"`
+
 	// Target defines where to store the outputs
-	Target TargetSpec `json:"target"`
+	// +optional
+	Target TargetSpec `json:"target,omitempty"`
+
+	// Targets defines additional places to store the outputs, each synced independently
+	// +optional
+	Targets []TargetSpec `json:"targets,omitempty"`
+}
+
+// OutputsSpec selects the outputs written to the target. Selectors are glob patterns such as
+// db_*, or regular expressions when wrapped in slashes such as /^(db|cache)_.*$/.
+type OutputsSpec struct {
+	// Include lists the selectors of the outputs to sync. All outputs are synced when empty.
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude lists the selectors of the outputs not to sync, applied after include
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Flatten expands map and list outputs into a key per nested value
+	// +optional
+	Flatten *FlattenSpec `json:"flatten,omitempty"`
+
+	// Mappings rename selected outputs to the given keys
+	// +optional
+	Mappings []OutputMapping `json:"mappings,omitempty"`
+
+	// Transform changes the keys of the selected outputs that are not renamed by a mapping
+	// +optional
+	Transform *OutputTransformSpec `json:"transform,omitempty"`
+
+	// Templates render additional keys from the merged outputs
+	// +optional
+	Templates []OutputTemplate `json:"templates,omitempty"`
+}
+
+// OutputTemplate renders a key from a Go text/template
+type OutputTemplate struct {
+	// Key the rendered template is written to
+	Key string `json:"key"`
+
+	// Template is rendered with the merged outputs of all backends as data, before filtering,
+	// e.g. jdbc:postgresql://{{ .db_host }}:{{ .db_port }}/{{ .db_name }}. Sprig functions
+	// are available.
+	Template string `json:"template"`
+
+	// Sensitive writes the rendered value to the Secret. Templates referencing a sensitive
+	// output are always written to the Secret.
+	// +optional
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// FlattenSpec expands map and list outputs into a key per nested value, such as
+// vpc.subnets.0.id
+type FlattenSpec struct {
+	// Outputs lists the selectors of the outputs to flatten, in the format of include.
+	// All map and list outputs are flattened when empty.
+	// +optional
+	Outputs []string `json:"outputs,omitempty"`
+
+	// Separator joins the output name, map keys and list indexes
+	// +kubebuilder:default="."
+	// +optional
+	Separator string `json:"separator,omitempty"`
+
+	// MaxDepth is the number of nesting levels expanded. Deeper values are written as JSON.
+	// +kubebuilder:default=5
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxDepth int `json:"maxDepth,omitempty"`
+}
+
+// OutputMapping renames an output
+type OutputMapping struct {
+	// From is the name of the Terraform output
+	From string `json:"from"`
+
+	// To is the key the output is written to
+	To string `json:"to"`
+
+	// Backend is the name of the backend to read the output from. The merged output of all
+	// backends is used when unset.
+	// +optional
+	Backend string `json:"backend,omitempty"`
+}
+
+// OutputTransformSpec changes the keys outputs are written to
+type OutputTransformSpec struct {
+	// Case converts the output names. Upper upper-cases them, ScreamingSnake also replaces
+	// separators and camelCase boundaries with underscores (rds-primary.endpoint becomes
+	// RDS_PRIMARY_ENDPOINT).
+	// +kubebuilder:validation:Enum=Upper;ScreamingSnake
+	// +optional
+	Case string `json:"case,omitempty"`
+
+	// Prefix is prepended to the keys, after the case conversion
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Suffix is appended to the keys, after the case conversion
+	// +optional
+	Suffix string `json:"suffix,omitempty"`
 }
 
 // BackendSpec defines a backend configuration
 // Exactly one backend configuration must be specified.
 type BackendSpec struct {
+	// Name identifies the backend in annotations, metrics, status and logs. It must be unique
+	// within the resource (default: <type>-<index>, e.g. s3-0).
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=32
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// KeyPrefix is prepended to the names of the outputs of this backend
+	// +optional
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+
 	// S3 defines the S3 backend configuration
 	// +optional
 	S3 *S3Spec `json:"s3,omitempty"`
+
+	// GCS defines the Google Cloud Storage backend configuration
+	// +optional
+	GCS *GCSSpec `json:"gcs,omitempty"`
+
+	// AzureRM defines the Azure Blob Storage (azurerm) backend configuration
+	// +optional
+	AzureRM *AzureRMSpec `json:"azurerm,omitempty"`
+
+	// Remote defines the Terraform Cloud / Terraform Enterprise (remote or cloud) backend configuration
+	// +optional
+	Remote *RemoteSpec `json:"remote,omitempty"`
+
+	// Kubernetes defines the Kubernetes Secret (terraform kubernetes backend) configuration
+	// +optional
+	Kubernetes *KubernetesSpec `json:"kubernetes,omitempty"`
+
+	// PG defines the PostgreSQL (terraform pg backend) configuration
+	// +optional
+	PG *PGSpec `json:"pg,omitempty"`
+
+	// Consul defines the Consul KV backend configuration
+	// +optional
+	Consul *ConsulSpec `json:"consul,omitempty"`
+
+	// HTTP defines the HTTP (terraform http backend) configuration, e.g. GitLab-managed state
+	// +optional
+	HTTP *HTTPSpec `json:"http,omitempty"`
+
+	// File defines a state file mounted into the controller or stored in a ConfigMap
+	// +optional
+	File *FileSpec `json:"file,omitempty"`
+
+	// Encryption decrypts OpenTofu client-side encrypted state read from this backend
+	// +optional
+	Encryption *StateEncryptionSpec `json:"encryption,omitempty"`
+}
+
+// StateEncryptionSpec defines the key material for OpenTofu state encrypted with the
+// aes_gcm method. Exactly one of PassphraseSecretRef or KeySecretRef must be set.
+type StateEncryptionSpec struct {
+	// PassphraseSecretRef references the passphrase of a pbkdf2 key provider in a Secret
+	// in the TerraformOutputs namespace
+	// +optional
+	PassphraseSecretRef *SecretKeyReference `json:"passphraseSecretRef,omitempty"`
+
+	// KeySecretRef references a 16, 24 or 32 byte AES-GCM key, raw or base64 encoded, in a
+	// Secret in the TerraformOutputs namespace
+	// +optional
+	KeySecretRef *SecretKeyReference `json:"keySecretRef,omitempty"`
+
+	// AAD is the additional authenticated data configured on the aes_gcm method
+	// +optional
+	AAD string `json:"aad,omitempty"`
 }
 
 // S3Spec defines S3 backend configuration
@@ -35,8 +225,32 @@ type S3Spec struct {
 	// Bucket is the S3 bucket name
 	Bucket string `json:"bucket"`
 
-	// Key is the path to the terraform state file
-	Key string `json:"key"`
+	// Key is the path to the terraform state file. Non-default workspaces are read from
+	// <workspaceKeyPrefix>/<workspace>/<key>, like the Terraform s3 backend.
+	// Exactly one of Key or KeyPattern must be set.
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// KeyPattern discovers many state files instead of reading a single Key. A pattern ending
+	// in / is a prefix matching every *.tfstate object below it, otherwise it is a glob such as
+	// services/*/terraform.tfstate. Output keys are prefixed with the matched path segments.
+	// A pattern without a wildcard must end in /.
+	// +optional
+	KeyPattern string `json:"keyPattern,omitempty"`
+
+	// Workspace is the Terraform workspace to read (default: default)
+	// +optional
+	Workspace string `json:"workspace,omitempty"`
+
+	// WorkspaceKeyPrefix is the prefix of non-default workspace state keys (default: env:)
+	// +optional
+	WorkspaceKeyPrefix string `json:"workspaceKeyPrefix,omitempty"`
+
+	// AllWorkspaces reads the state of every workspace found under WorkspaceKeyPrefix,
+	// including the default workspace. Output keys are prefixed with <workspace>_.
+	// Workspace must not be set together with AllWorkspaces.
+	// +optional
+	AllWorkspaces bool `json:"allWorkspaces,omitempty"`
 
 	// Region is the AWS region
 	Region string `json:"region"`
@@ -48,6 +262,261 @@ type S3Spec struct {
 	// Role is the IAM role to assume for accessing the S3 bucket
 	// +optional
 	Role string `json:"role,omitempty"`
+
+	// RoleSessionName is the session name used when assuming Role (default: tfout-<namespace>-<name>)
+	// +optional
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+
+	// RoleExternalID is the external ID used when assuming Role
+	// +optional
+	RoleExternalID string `json:"roleExternalID,omitempty"`
+
+	// RoleDuration is the duration of the assumed role session (e.g. 1h, default: 15m)
+	// +optional
+	RoleDuration string `json:"roleDuration,omitempty"`
+
+	// STSEndpoint is an optional custom STS endpoint used when assuming Role
+	// +optional
+	STSEndpoint string `json:"stsEndpoint,omitempty"`
+
+	// CredentialsSecretRef references static AWS credentials in a Secret in the TerraformOutputs
+	// namespace. When set, only these credentials are used for this backend (also to assume Role)
+	// instead of the controller's own credentials.
+	// +optional
+	CredentialsSecretRef *AWSCredentialsSecretReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// AWSCredentialsSecretReference references static AWS credentials in a Secret in the TerraformOutputs namespace
+type AWSCredentialsSecretReference struct {
+	// Name of the Secret
+	Name string `json:"name"`
+
+	// AccessKeyIDKey is the Secret key holding the access key ID
+	// +kubebuilder:default="AWS_ACCESS_KEY_ID"
+	// +optional
+	AccessKeyIDKey string `json:"accessKeyIDKey,omitempty"`
+
+	// SecretAccessKeyKey is the Secret key holding the secret access key
+	// +kubebuilder:default="AWS_SECRET_ACCESS_KEY"
+	// +optional
+	SecretAccessKeyKey string `json:"secretAccessKeyKey,omitempty"`
+
+	// SessionTokenKey is the Secret key holding the optional session token
+	// +kubebuilder:default="AWS_SESSION_TOKEN"
+	// +optional
+	SessionTokenKey string `json:"sessionTokenKey,omitempty"`
+}
+
+// GCSSpec defines Google Cloud Storage backend configuration.
+// The state object is resolved the same way as the Terraform gcs backend:
+// <prefix>/<workspace>.tfstate, unless Object is set explicitly.
+type GCSSpec struct {
+	// Bucket is the GCS bucket name
+	Bucket string `json:"bucket"`
+
+	// Prefix is the object path prefix used by the Terraform gcs backend
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Object is the full path to the terraform state file, overriding Prefix and Workspace
+	// +optional
+	Object string `json:"object,omitempty"`
+
+	// Workspace is the Terraform workspace name (default: "default")
+	// +optional
+	Workspace string `json:"workspace,omitempty"`
+
+	// Endpoint is optional GCS-compatible endpoint (e.g. a local fake GCS server)
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// CredentialsSecretRef references a service account JSON key in a Secret in the
+	// TerraformOutputs namespace. When unset, workload identity or the controller's
+	// application default credentials are used.
+	// +optional
+	CredentialsSecretRef *SecretKeyReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// AzureRMSpec defines Azure Blob Storage (azurerm) backend configuration.
+// Without a SAS token or access key, the controller authenticates with
+// managed identity (or any other credential supported by DefaultAzureCredential).
+type AzureRMSpec struct {
+	// StorageAccountName is the Azure storage account name
+	StorageAccountName string `json:"storageAccountName"`
+
+	// ContainerName is the blob container holding the state file
+	ContainerName string `json:"containerName"`
+
+	// Key is the name of the state blob
+	Key string `json:"key"`
+
+	// Workspace is the Terraform workspace name, stored as <key>env:<workspace> by the azurerm backend
+	// +optional
+	Workspace string `json:"workspace,omitempty"`
+
+	// Endpoint is optional blob service endpoint (e.g. Azurite), defaults to
+	// https://<storageAccountName>.blob.core.windows.net
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// ClientID selects a user-assigned managed identity
+	// +optional
+	ClientID string `json:"clientID,omitempty"`
+
+	// SASTokenSecretRef references a SAS token in a Secret in the TerraformOutputs namespace
+	// +optional
+	SASTokenSecretRef *SecretKeyReference `json:"sasTokenSecretRef,omitempty"`
+
+	// AccessKeySecretRef references a storage account key in a Secret in the TerraformOutputs namespace
+	// +optional
+	AccessKeySecretRef *SecretKeyReference `json:"accessKeySecretRef,omitempty"`
+}
+
+// RemoteSpec defines Terraform Cloud / Terraform Enterprise workspace backend configuration
+type RemoteSpec struct {
+	// Hostname of Terraform Cloud or a self-hosted Terraform Enterprise instance.
+	// A scheme may be included, e.g. http://tfe.local:8080
+	// +kubebuilder:default="app.terraform.io"
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
+
+	// Organization is the Terraform Cloud organization name
+	Organization string `json:"organization"`
+
+	// Workspace is the name of the workspace to read outputs from
+	Workspace string `json:"workspace"`
+
+	// TokenSecretRef references an API token in a Secret in the TerraformOutputs namespace
+	TokenSecretRef SecretKeyReference `json:"tokenSecretRef"`
+}
+
+// KubernetesSpec defines Kubernetes Secret backend configuration.
+// The state is read from the Secret tfstate-<workspace>-<secretSuffix> written by the
+// Terraform kubernetes backend.
+type KubernetesSpec struct {
+	// SecretSuffix is the secret_suffix configured in the Terraform kubernetes backend
+	SecretSuffix string `json:"secretSuffix"`
+
+	// Workspace is the Terraform workspace name (default: "default")
+	// +optional
+	Workspace string `json:"workspace,omitempty"`
+
+	// Namespace of the state Secret, defaults to the TerraformOutputs namespace. Other
+	// namespaces require the controller's --allow-cross-namespace-state-secrets flag.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PGSpec defines PostgreSQL backend configuration.
+// The state is read from the <schemaName>.states table written by the Terraform pg backend.
+type PGSpec struct {
+	// ConnectionStringSecretRef references a PostgreSQL connection string in a Secret
+	// in the TerraformOutputs namespace
+	ConnectionStringSecretRef SecretKeyReference `json:"connectionStringSecretRef"`
+
+	// SchemaName is the schema holding the states table
+	// +kubebuilder:default="terraform_remote_state"
+	// +optional
+	SchemaName string `json:"schemaName,omitempty"`
+
+	// Workspace is the Terraform workspace name (default: "default")
+	// +optional
+	Workspace string `json:"workspace,omitempty"`
+}
+
+// ConsulSpec defines Consul KV backend configuration.
+// Gzip compressed state (terraform consul backend gzip = true) is detected automatically.
+type ConsulSpec struct {
+	// Address of the Consul agent, e.g. consul.consul.svc:8500
+	Address string `json:"address"`
+
+	// Scheme used to connect to the Consul agent
+	// +kubebuilder:validation:Enum=http;https
+	// +kubebuilder:default="http"
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+
+	// Path is the KV path of the state
+	Path string `json:"path"`
+
+	// Datacenter to read from, defaults to the datacenter of the agent
+	// +optional
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// TokenSecretRef references an ACL token in a Secret in the TerraformOutputs namespace
+	// +optional
+	TokenSecretRef *SecretKeyReference `json:"tokenSecretRef,omitempty"`
+
+	// TLS defines the TLS options used when Scheme is https
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+}
+
+// HTTPSpec defines HTTP backend configuration
+type HTTPSpec struct {
+	// Address is the URL of the state, e.g. https://gitlab.com/api/v4/projects/<id>/terraform/state/<name>
+	Address string `json:"address"`
+
+	// UsernameSecretRef references the basic auth username in a Secret in the TerraformOutputs namespace
+	// +optional
+	UsernameSecretRef *SecretKeyReference `json:"usernameSecretRef,omitempty"`
+
+	// PasswordSecretRef references the basic auth password (or access token) in a Secret
+	// in the TerraformOutputs namespace
+	// +optional
+	PasswordSecretRef *SecretKeyReference `json:"passwordSecretRef,omitempty"`
+
+	// TLS defines the TLS options such as a custom CA or skipping verification
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+}
+
+// FileSpec defines file backend configuration. Exactly one of Path or ConfigMapRef must be set.
+type FileSpec struct {
+	// Path of the state file, relative to the controller's --state-dir volume
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// ConfigMapRef references a key of a ConfigMap in the TerraformOutputs namespace holding the state
+	// +optional
+	ConfigMapRef *ConfigMapKeyReference `json:"configMapRef,omitempty"`
+}
+
+// ConfigMapKeyReference references a key of a ConfigMap in the TerraformOutputs namespace
+type ConfigMapKeyReference struct {
+	// Name of the ConfigMap
+	Name string `json:"name"`
+
+	// Key within the ConfigMap
+	Key string `json:"key"`
+}
+
+// TLSSpec defines TLS client options for backends reached over HTTPS
+type TLSSpec struct {
+	// CACertSecretRef references a PEM encoded CA bundle in a Secret in the TerraformOutputs namespace
+	// +optional
+	CACertSecretRef *SecretKeyReference `json:"caCertSecretRef,omitempty"`
+
+	// ClientCertSecretRef references a PEM encoded client certificate in a Secret in the TerraformOutputs namespace
+	// +optional
+	ClientCertSecretRef *SecretKeyReference `json:"clientCertSecretRef,omitempty"`
+
+	// ClientKeySecretRef references a PEM encoded client key in a Secret in the TerraformOutputs namespace
+	// +optional
+	ClientKeySecretRef *SecretKeyReference `json:"clientKeySecretRef,omitempty"`
+
+	// InsecureSkipVerify disables server certificate verification
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// SecretKeyReference references a key of a Secret in the TerraformOutputs namespace
+type SecretKeyReference struct {
+	// Name of the Secret
+	Name string `json:"name"`
+
+	// Key within the Secret
+	Key string `json:"key"`
 }
 
 // TargetSpec defines where outputs should be stored
@@ -56,6 +525,11 @@ type TargetSpec struct {
 	// +kubebuilder:default="default"
 	Namespace string `json:"namespace,omitempty"`
 
+	// NamespaceSelector creates the ConfigMap/Secret in every namespace matching the selector
+	// instead of Namespace. Copies in namespaces that stop matching are deleted.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
 	// ConfigMapName for non-sensitive outputs
 	// +optional
 	ConfigMapName string `json:"configMapName,omitempty"`
@@ -63,6 +537,10 @@ type TargetSpec struct {
 	// SecretName for sensitive outputs (automatically determined from Terraform state)
 	// +optional
 	SecretName string `json:"secretName,omitempty"`
+
+	// Outputs selects the merged outputs written to this target, instead of spec.outputs
+	// +optional
+	Outputs *OutputsSpec `json:"outputs,omitempty"`
 }
 
 // TerraformOutputsStatus defines the observed state of TerraformOutputs
@@ -79,15 +557,94 @@ type TerraformOutputsStatus struct {
 	// +optional
 	Message string `json:"message,omitempty"`
 
-	// OutputCount is the number of outputs found
+	// OutputCount is the number of outputs synced
 	// +optional
 	OutputCount int `json:"outputCount,omitempty"`
 
+	// FilteredOutputCount is the number of outputs dropped by spec.outputs
+	// +optional
+	FilteredOutputCount int `json:"filteredOutputCount,omitempty"`
+
+	// ObservedGeneration is the generation of the spec that was last synced successfully
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Backends describes the Terraform state read from each backend in the last successful sync
+	// +optional
+	Backends []BackendStatus `json:"backends,omitempty"`
+
+	// Targets describes the result of the last sync of each target
+	// +optional
+	Targets []TargetStatus `json:"targets,omitempty"`
+
 	// Conditions represent the latest available observations
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// BackendStatus describes a Terraform state file read from a backend
+type BackendStatus struct {
+	// Name of the backend in spec.backends
+	Name string `json:"name"`
+
+	// Type of the backend
+	Type string `json:"type"`
+
+	// State is the key of the state file, for backends reading several state files
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// Lineage is the unique ID assigned to the state when it was created
+	// +optional
+	Lineage string `json:"lineage,omitempty"`
+
+	// Serial is incremented by every Terraform run that changes the state
+	// +optional
+	Serial int64 `json:"serial,omitempty"`
+
+	// TerraformVersion is the version of Terraform or OpenTofu that last wrote the state
+	// +optional
+	TerraformVersion string `json:"terraformVersion,omitempty"`
+
+	// StateVersion is the version of the state file format
+	// +optional
+	StateVersion int `json:"stateVersion,omitempty"`
+
+	// SHA256 is the hash of the state file content
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// TargetStatus describes the last sync of a target
+type TargetStatus struct {
+	// Namespace of the target
+	Namespace string `json:"namespace"`
+
+	// ConfigMapName of the target
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// SecretName of the target
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// SyncStatus is the result of the last sync of the target
+	// +kubebuilder:validation:Enum=Success;Failed
+	SyncStatus string `json:"syncStatus"`
+
+	// Message provides additional status information
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// OutputCount is the number of outputs synced to the target
+	// +optional
+	OutputCount int `json:"outputCount,omitempty"`
+
+	// LastSyncTime is when outputs were last synced to the target
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Bucket",type=string,JSONPath=`.spec.backends[0].source.bucket`
@@ -124,10 +681,34 @@ func (bs *BackendSpec) ValidateBackend() error {
 	if bs.S3 != nil {
 		configCount++
 	}
+	if bs.GCS != nil {
+		configCount++
+	}
+	if bs.AzureRM != nil {
+		configCount++
+	}
+	if bs.Remote != nil {
+		configCount++
+	}
+	if bs.Kubernetes != nil {
+		configCount++
+	}
+	if bs.PG != nil {
+		configCount++
+	}
+	if bs.Consul != nil {
+		configCount++
+	}
+	if bs.HTTP != nil {
+		configCount++
+	}
+	if bs.File != nil {
+		configCount++
+	}
 
-	if configCount == 0 {
+	if configCount != 1 {
 		return fmt.Errorf(
-			"exactly one backend configuration must be specified (s3)",
+			"exactly one backend configuration must be specified (s3, gcs, azurerm, remote, kubernetes, pg, consul, http, file)",
 		)
 	}
 
@@ -139,5 +720,29 @@ func (bs *BackendSpec) GetBackendType() string {
 	if bs.S3 != nil {
 		return "s3"
 	}
+	if bs.GCS != nil {
+		return "gcs"
+	}
+	if bs.AzureRM != nil {
+		return "azurerm"
+	}
+	if bs.Remote != nil {
+		return "remote"
+	}
+	if bs.Kubernetes != nil {
+		return "kubernetes"
+	}
+	if bs.PG != nil {
+		return "pg"
+	}
+	if bs.Consul != nil {
+		return "consul"
+	}
+	if bs.HTTP != nil {
+		return "http"
+	}
+	if bs.File != nil {
+		return "file"
+	}
 	return ""
 }
@@ -41,6 +41,8 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var stateDir string
+	var allowCrossNamespaceStateSecrets bool
 	flag.StringVar(
 		&metricsAddr,
 		"metrics-bind-address",
@@ -62,6 +64,11 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&stateDir, "state-dir", "",
+		"Directory file backend paths are resolved in, usually a mounted volume. "+
+			"File paths are disabled when empty.")
+	flag.BoolVar(&allowCrossNamespaceStateSecrets, "allow-cross-namespace-state-secrets", false,
+		"If set, kubernetes backends may read state Secrets in other namespaces than their TerraformOutputs")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -120,8 +127,10 @@ func main() {
 	}
 
 	if err = (&controller.TerraformOutputsReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                          mgr.GetClient(),
+		Scheme:                          mgr.GetScheme(),
+		StateDir:                        stateDir,
+		AllowCrossNamespaceStateSecrets: allowCrossNamespaceStateSecrets,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "TerraformOutputs")
 		os.Exit(1)
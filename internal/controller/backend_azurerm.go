@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// azureStateKey resolves the state blob name the same way as the Terraform azurerm backend,
+// which stores non-default workspaces in <key>env:<workspace>
+func azureStateKey(azureSpec outputsv1alpha1.AzureRMSpec) string {
+	if azureSpec.Workspace != "" && azureSpec.Workspace != "default" {
+		return azureSpec.Key + "env:" + azureSpec.Workspace
+	}
+	return azureSpec.Key
+}
+
+// azureBlobURL resolves the state blob URL the same way as the Terraform azurerm backend
+func azureBlobURL(azureSpec outputsv1alpha1.AzureRMSpec) (string, error) {
+	endpoint := azureSpec.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", azureSpec.StorageAccountName)
+	}
+
+	return url.JoinPath(endpoint, azureSpec.ContainerName, azureStateKey(azureSpec))
+}
+
+// azureCredentialCache holds one credential per Azure identity, so the backends using it
+// share its tokens. There are few identities, so credentials are never evicted.
+type azureCredentialCache struct {
+	mu          sync.Mutex
+	credentials map[string]azcore.TokenCredential
+}
+
+// get returns the credential of the identity, creating it when missing
+func (c *azureCredentialCache) get(
+	identity string,
+	create func() (azcore.TokenCredential, error),
+) (azcore.TokenCredential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cred, ok := c.credentials[identity]; ok {
+		return cred, nil
+	}
+	cred, err := create()
+	if err != nil {
+		return nil, err
+	}
+	if c.credentials == nil {
+		c.credentials = make(map[string]azcore.TokenCredential)
+	}
+	c.credentials[identity] = cred
+	return cred, nil
+}
+
+// newAzureBlobClient returns a cached blob client using a SAS token or account key from a
+// Secret, falling back to managed identity when neither is configured. Identity credentials
+// are cached too, so their tokens are reused by every blob they read.
+func (r *TerraformOutputsReconciler) newAzureBlobClient(
+	ctx context.Context,
+	azureSpec outputsv1alpha1.AzureRMSpec,
+	namespace string,
+) (*blob.Client, error) {
+	blobURL, err := azureBlobURL(azureSpec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob URL: %w", err)
+	}
+
+	// Secret contents are part of the cache key, so rotated credentials get a new client
+	switch {
+	case azureSpec.SASTokenSecretRef != nil:
+		sasToken, err := r.readSecretKey(ctx, namespace, *azureSpec.SASTokenSecretRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SAS token: %w", err)
+		}
+		sasURL := blobURL + "?" + strings.TrimPrefix(strings.TrimSpace(string(sasToken)), "?")
+		cacheKey := azureClientCacheKey("sas", blobURL, sasToken)
+		return r.azureClients.get("azurerm", cacheKey, func() (*blob.Client, error) {
+			return blob.NewClientWithNoCredential(sasURL, nil)
+		})
+
+	case azureSpec.AccessKeySecretRef != nil:
+		accessKey, err := r.readSecretKey(ctx, namespace, *azureSpec.AccessKeySecretRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read storage account key: %w", err)
+		}
+		cacheKey := azureClientCacheKey("shared-key", blobURL, accessKey)
+		return r.azureClients.get("azurerm", cacheKey, func() (*blob.Client, error) {
+			cred, err := blob.NewSharedKeyCredential(
+				azureSpec.StorageAccountName,
+				strings.TrimSpace(string(accessKey)),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("invalid storage account key: %w", err)
+			}
+			return blob.NewClientWithSharedKeyCredential(blobURL, cred, nil)
+		})
+
+	default:
+		// Backends using the same identity share its credential and tokens
+		identity := "default"
+		if azureSpec.ClientID != "" {
+			identity = "managed-identity|" + azureSpec.ClientID
+		}
+		cred, err := r.azureCredentials.get(identity, func() (azcore.TokenCredential, error) {
+			if azureSpec.ClientID != "" {
+				cred, err := azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+					ID: azidentity.ClientID(azureSpec.ClientID),
+				})
+				if err != nil {
+					return nil, fmt.Errorf("failed to create managed identity credential: %w", err)
+				}
+				return cred, nil
+			}
+			cred, err := azidentity.NewDefaultAzureCredential(nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create Azure credential: %w", err)
+			}
+			return cred, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		cacheKey := azureClientCacheKey(identity, blobURL, nil)
+		return r.azureClients.get("azurerm", cacheKey, func() (*blob.Client, error) {
+			return blob.NewClient(blobURL, cred, nil)
+		})
+	}
+}
+
+// azureClientCacheKey returns the cache key of a blob client, hashing the secret it
+// authenticates with
+func azureClientCacheKey(auth, blobURL string, secret []byte) string {
+	secretHash := sha256.Sum256(secret)
+	return strings.Join([]string{auth, blobURL, hex.EncodeToString(secretHash[:])}, "|")
+}
+
+// getAzureBlobETag gets the ETag of an Azure blob without downloading it
+func (r *TerraformOutputsReconciler) getAzureBlobETag(
+	ctx context.Context,
+	azureSpec outputsv1alpha1.AzureRMSpec,
+	namespace, name string,
+) (string, error) {
+	blobClient, err := r.newAzureBlobClient(ctx, azureSpec, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	azureLabels := prometheus.Labels{
+		"namespace":    namespace,
+		"name":         name,
+		"backend_type": "azurerm",
+		"operation":    "GetProperties",
+	}
+
+	props, err := blobClient.GetProperties(ctx, nil)
+	if err != nil {
+		azureLabels["result"] = resultError
+		backendRequestsTotal.With(azureLabels).Inc()
+		return "", fmt.Errorf("failed to get blob properties: %w", err)
+	}
+
+	azureLabels["result"] = resultSuccess
+	backendRequestsTotal.With(azureLabels).Inc()
+
+	if props.ETag == nil {
+		return "", fmt.Errorf("blob properties did not include an ETag")
+	}
+
+	return strings.Trim(string(*props.ETag), "\""), nil
+}
+
+// fetchTerraformOutputsFromAzure fetches outputs from a single azurerm backend
+func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromAzure(
+	ctx context.Context,
+	azureSpec outputsv1alpha1.AzureRMSpec,
+	backendName string,
+	namespace, name string,
+) (map[string]interface{}, map[string]bool, error) {
+	logger := log.FromContext(ctx)
+
+	blobClient, err := r.newAzureBlobClient(ctx, azureSpec, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Download state file
+	logger.Info(
+		"Downloading Terraform state",
+		"backend",
+		backendName,
+		"storageAccount",
+		azureSpec.StorageAccountName,
+		"container",
+		azureSpec.ContainerName,
+		"key",
+		azureStateKey(azureSpec),
+	)
+
+	azureLabels := prometheus.Labels{
+		"namespace":    namespace,
+		"name":         name,
+		"backend_type": "azurerm",
+		"operation":    "DownloadStream",
+	}
+
+	result, err := blobClient.DownloadStream(ctx, nil)
+	if err != nil {
+		azureLabels["result"] = resultError
+		backendRequestsTotal.With(azureLabels).Inc()
+		return nil, nil, fmt.Errorf("failed to download state file: %w", err)
+	}
+	defer func() {
+		if err := result.Body.Close(); err != nil {
+			logger.Error(err, "Failed to close Azure blob response body")
+		}
+	}()
+
+	azureLabels["result"] = resultSuccess
+	backendRequestsTotal.With(azureLabels).Inc()
+
+	body, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read state file body: %w", err)
+	}
+
+	return parseTerraformOutputs(ctx, body)
+}
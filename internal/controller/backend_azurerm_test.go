@@ -0,0 +1,164 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// azuriteAccountKey is the well-known Azurite development storage account key
+const azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+var _ = Describe("AzureRM backend", func() {
+	It("should resolve blob URLs like the terraform azurerm backend", func() {
+		blobURL, err := azureBlobURL(outputsv1alpha1.AzureRMSpec{
+			StorageAccountName: "myaccount",
+			ContainerName:      "tfstate",
+			Key:                "prod.terraform.tfstate",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blobURL).To(Equal("https://myaccount.blob.core.windows.net/tfstate/prod.terraform.tfstate"))
+
+		blobURL, err = azureBlobURL(outputsv1alpha1.AzureRMSpec{
+			StorageAccountName: "devstoreaccount1",
+			ContainerName:      "tfstate",
+			Key:                "prod.terraform.tfstate",
+			Workspace:          "blue",
+			Endpoint:           "http://127.0.0.1:10000/devstoreaccount1",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blobURL).To(Equal("http://127.0.0.1:10000/devstoreaccount1/tfstate/prod.terraform.tfstateenv:blue"))
+		Expect(azureStateKey(outputsv1alpha1.AzureRMSpec{Key: "prod.terraform.tfstate", Workspace: "default"})).
+			To(Equal("prod.terraform.tfstate"))
+	})
+
+	Context("When reconciling a resource with an azurerm backend", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+
+		BeforeEach(func() {
+			stateBytes, _ := json.Marshal(map[string]interface{}{
+				"outputs": map[string]interface{}{
+					"storage_account_key": map[string]interface{}{
+						"value":     "very-secret",
+						"sensitive": true,
+					},
+				},
+			})
+
+			// Mock the blob endpoints of Azurite, requiring shared key authentication
+			mockAzuriteServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey devstoreaccount1:") {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					if r.URL.Path != "/devstoreaccount1/tfstate/prod.terraform.tfstate" {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					w.Header().Set("ETag", "\"0x8DBEEF\"")
+					if r.Method == http.MethodGet {
+						_, err := w.Write(stateBytes)
+						Expect(err).NotTo(HaveOccurred())
+					}
+				}),
+			)
+			DeferCleanup(mockAzuriteServer.Close)
+
+			resource = newTestTerraformOutputs("test-azurerm-resource", outputsv1alpha1.BackendSpec{
+				Name: "azurerm",
+				AzureRM: &outputsv1alpha1.AzureRMSpec{
+					StorageAccountName: "devstoreaccount1",
+					ContainerName:      "tfstate",
+					Key:                "prod.terraform.tfstate",
+					Endpoint:           mockAzuriteServer.URL + "/devstoreaccount1",
+					AccessKeySecretRef: &outputsv1alpha1.SecretKeyReference{
+						Name: "azurite-credentials",
+						Key:  "accessKey",
+					},
+				},
+			})
+			createTestObjects(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "azurite-credentials",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{"accessKey": []byte(azuriteAccountKey)},
+			}, resource)
+		})
+
+		It("should sync outputs using the account key from the referenced Secret", func() {
+			Expect(reconcileTestTerraformOutputs(ctx, newTestReconciler(), resource)).To(Succeed())
+			Expect(string(syncedSecret(ctx, resource).Data["storage_account_key"])).To(Equal("very-secret"))
+			Expect(resource.Annotations).To(HaveKeyWithValue(AzureETagAnnotationPrefix+"azurerm", "0x8DBEEF"))
+		})
+
+		It("should reuse the blob client until the account key changes", func() {
+			controllerReconciler := newTestReconciler()
+			azureSpec := *resource.Spec.Backends[0].AzureRM
+
+			first, err := controllerReconciler.newAzureBlobClient(ctx, azureSpec, "default")
+			Expect(err).NotTo(HaveOccurred())
+			second, err := controllerReconciler.newAzureBlobClient(ctx, azureSpec, "default")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second).To(BeIdenticalTo(first))
+
+			By("Creating a new client for a rotated key")
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "azurite-credentials", Namespace: "default"}, secret)).
+				To(Succeed())
+			secret.Data["accessKey"] = []byte("cm90YXRlZC1rZXk=")
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+			third, err := controllerReconciler.newAzureBlobClient(ctx, azureSpec, "default")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(third).NotTo(BeIdenticalTo(first))
+		})
+	})
+
+	It("should share the credential of a managed identity between blobs", func() {
+		ctx := context.Background()
+		controllerReconciler := newTestReconciler()
+		azureSpec := outputsv1alpha1.AzureRMSpec{
+			StorageAccountName: "myaccount",
+			ContainerName:      "tfstate",
+			Key:                "network.terraform.tfstate",
+			ClientID:           "00000000-0000-0000-0000-000000000000",
+		}
+
+		network, err := controllerReconciler.newAzureBlobClient(ctx, azureSpec, "default")
+		Expect(err).NotTo(HaveOccurred())
+		azureSpec.Key = "database.terraform.tfstate"
+		database, err := controllerReconciler.newAzureBlobClient(ctx, azureSpec, "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(database).NotTo(BeIdenticalTo(network))
+		Expect(controllerReconciler.azureCredentials.credentials).To(HaveLen(1))
+		Expect(controllerReconciler.azureClients.entries).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,518 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const (
+	// consulBlockingWait is the maximum duration of a Consul blocking query
+	consulBlockingWait = 5 * time.Minute
+	// consulWatchRetryInterval is the delay before retrying a failed blocking query
+	consulWatchRetryInterval = 10 * time.Second
+)
+
+// consulKVPair is a single entry returned by the Consul KV API
+type consulKVPair struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// consulClient is a minimal client for the Consul KV API
+type consulClient struct {
+	baseURL    string
+	datacenter string
+	token      string
+	httpClient *http.Client
+	namespace  string
+	name       string
+}
+
+// newConsulClient creates a Consul KV client using the token and TLS options from the referenced Secrets
+func (r *TerraformOutputsReconciler) newConsulClient(
+	ctx context.Context,
+	consulSpec outputsv1alpha1.ConsulSpec,
+	namespace, name string,
+) (*consulClient, error) {
+	token, tls, err := r.readConsulCredentials(ctx, consulSpec, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return r.consulClientFor(consulSpec, token, tls, namespace, name)
+}
+
+// readConsulCredentials reads the token and TLS material referenced by a Consul backend
+func (r *TerraformOutputsReconciler) readConsulCredentials(
+	ctx context.Context,
+	consulSpec outputsv1alpha1.ConsulSpec,
+	namespace string,
+) (string, tlsMaterial, error) {
+	var token string
+	if consulSpec.TokenSecretRef != nil {
+		value, err := r.readSecretKey(ctx, namespace, *consulSpec.TokenSecretRef)
+		if err != nil {
+			return "", tlsMaterial{}, fmt.Errorf("failed to read Consul token: %w", err)
+		}
+		token = strings.TrimSpace(string(value))
+	}
+
+	tls, err := r.readTLSMaterial(ctx, namespace, consulSpec.TLS)
+	if err != nil {
+		return "", tlsMaterial{}, err
+	}
+
+	return token, tls, nil
+}
+
+// consulClientFor creates a Consul KV client on the shared transport of its TLS configuration
+func (r *TerraformOutputsReconciler) consulClientFor(
+	consulSpec outputsv1alpha1.ConsulSpec,
+	token string,
+	tls tlsMaterial,
+	namespace, name string,
+) (*consulClient, error) {
+	transport, err := r.httpTransports.get(tls)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := consulSpec.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	return &consulClient{
+		baseURL:    fmt.Sprintf("%s://%s", scheme, strings.TrimSuffix(consulSpec.Address, "/")),
+		datacenter: consulSpec.Datacenter,
+		token:      token,
+		namespace:  namespace,
+		name:       name,
+		// Blocking queries may take up to consulBlockingWait (plus jitter) to return
+		httpClient: &http.Client{Timeout: consulBlockingWait + time.Minute, Transport: transport},
+	}, nil
+}
+
+// consulKVPath escapes each segment of a KV key, so keys containing characters such as
+// ? or # are not read as a query or fragment
+func consulKVPath(key string) string {
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// getKV reads a key from the Consul KV store. When index is non-zero, a blocking query
+// is performed which returns once the key changes or wait elapses.
+// A missing key returns a nil pair.
+func (c *consulClient) getKV(
+	ctx context.Context,
+	path string,
+	index uint64,
+	wait time.Duration,
+) (*consulKVPair, uint64, error) {
+	query := url.Values{}
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	operation := "GetKV"
+	if index > 0 {
+		operation = "WatchKV"
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(wait.Seconds())))
+	}
+
+	consulLabels := prometheus.Labels{
+		"namespace":    c.namespace,
+		"name":         c.name,
+		"backend_type": "consul",
+		"operation":    operation,
+	}
+
+	requestURL := fmt.Sprintf("%s/v1/kv/%s?%s", c.baseURL, consulKVPath(path), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		consulLabels["result"] = resultError
+		backendRequestsTotal.With(consulLabels).Inc()
+		return nil, 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	consulIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	if resp.StatusCode == http.StatusNotFound {
+		consulLabels["result"] = resultSuccess
+		backendRequestsTotal.With(consulLabels).Inc()
+		return nil, consulIndex, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		consulLabels["result"] = resultError
+		backendRequestsTotal.With(consulLabels).Inc()
+		return nil, 0, fmt.Errorf("unexpected status %d from Consul", resp.StatusCode)
+	}
+
+	consulLabels["result"] = resultSuccess
+	backendRequestsTotal.With(consulLabels).Inc()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var pairs []consulKVPair
+	if err := json.Unmarshal(body, &pairs); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse Consul response: %w", err)
+	}
+	if len(pairs) == 0 {
+		return nil, consulIndex, nil
+	}
+
+	return &pairs[0], consulIndex, nil
+}
+
+// getConsulModifyIndex gets the ModifyIndex of the state key
+func (r *TerraformOutputsReconciler) getConsulModifyIndex(
+	ctx context.Context,
+	consulSpec outputsv1alpha1.ConsulSpec,
+	namespace, name string,
+) (string, error) {
+	c, err := r.newConsulClient(ctx, consulSpec, namespace, name)
+	if err != nil {
+		return "", err
+	}
+
+	pair, _, err := c.getKV(ctx, consulSpec.Path, 0, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Consul key: %w", err)
+	}
+	if pair == nil {
+		return "", fmt.Errorf("consul key %q not found", consulSpec.Path)
+	}
+
+	return strconv.FormatUint(pair.ModifyIndex, 10), nil
+}
+
+// fetchTerraformOutputsFromConsul fetches outputs from a single Consul KV backend
+func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromConsul(
+	ctx context.Context,
+	consulSpec outputsv1alpha1.ConsulSpec,
+	backendName string,
+	namespace, name string,
+) (map[string]interface{}, map[string]bool, error) {
+	logger := log.FromContext(ctx)
+
+	c, err := r.newConsulClient(ctx, consulSpec, namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Info(
+		"Reading Terraform state from Consul",
+		"backend",
+		backendName,
+		"address",
+		consulSpec.Address,
+		"path",
+		consulSpec.Path,
+	)
+
+	pair, _, err := c.getKV(ctx, consulSpec.Path, 0, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read Consul key: %w", err)
+	}
+	if pair == nil {
+		return nil, nil, fmt.Errorf("consul key %q not found", consulSpec.Path)
+	}
+
+	body := pair.Value
+	// The terraform consul backend gzips the state when gzip = true
+	if len(body) > 1 && body[0] == 0x1f && body[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress state: %w", err)
+		}
+		body, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress state: %w", err)
+		}
+	}
+
+	return parseTerraformOutputs(ctx, body)
+}
+
+// consulWatch is a running blocking query against a single Consul backend
+type consulWatch struct {
+	fingerprint string
+	cancel      context.CancelFunc
+	modifyIndex atomic.Uint64
+}
+
+// consulWatchManager runs Consul blocking queries in the background and triggers a
+// reconcile of the owning TerraformOutputs as soon as the state key changes
+type consulWatchManager struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	watches map[string]*consulWatch
+	events  chan event.GenericEvent
+}
+
+// newConsulWatchManager creates a consulWatchManager, it has to be added to the manager to start watching
+func newConsulWatchManager() *consulWatchManager {
+	return &consulWatchManager{
+		watches: make(map[string]*consulWatch),
+		events:  make(chan event.GenericEvent, 1024),
+	}
+}
+
+// Start implements manager.Runnable and stops all watches once the manager stops
+func (m *consulWatchManager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	m.ctx = ctx
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, w := range m.watches {
+		w.cancel()
+		delete(m.watches, key)
+	}
+	return nil
+}
+
+// consulWatchKey identifies the watch of a single backend of a TerraformOutputs
+func consulWatchKey(nn types.NamespacedName, backendName string) string {
+	return fmt.Sprintf("%s/%s", nn, backendName)
+}
+
+// consulWatchFingerprint changes whenever the watch has to be restarted
+func consulWatchFingerprint(consulSpec outputsv1alpha1.ConsulSpec, token string, tls tlsMaterial) string {
+	spec, _ := json.Marshal(consulSpec)
+	sum := sha256.Sum256(append(append(spec, token...), tls.fingerprint()...))
+	return hex.EncodeToString(sum[:])
+}
+
+// running reports whether the watch of a backend runs with the given fingerprint
+func (m *consulWatchManager) running(nn types.NamespacedName, backendName, fingerprint string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.watches[consulWatchKey(nn, backendName)]
+	return ok && w.fingerprint == fingerprint
+}
+
+// fingerprint returns the fingerprint of the running watch of a backend
+func (m *consulWatchManager) fingerprint(nn types.NamespacedName, backendName string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.watches[consulWatchKey(nn, backendName)]
+	if !ok {
+		return "", false
+	}
+	return w.fingerprint, true
+}
+
+// modifyIndex returns the last ModifyIndex observed by the watch of a backend
+func (m *consulWatchManager) modifyIndex(nn types.NamespacedName, backendName string) (uint64, bool) {
+	if m == nil {
+		return 0, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.watches[consulWatchKey(nn, backendName)]
+	if !ok || w.modifyIndex.Load() == 0 {
+		return 0, false
+	}
+	return w.modifyIndex.Load(), true
+}
+
+// sync starts, restarts and stops the watches of a TerraformOutputs so they match the desired
+// fingerprints. desired holds the clients of the watches to start, watches already running with
+// their fingerprint are kept.
+func (m *consulWatchManager) sync(
+	nn types.NamespacedName,
+	desired map[string]*consulClient,
+	paths map[string]string,
+	fingerprints map[string]string,
+) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := nn.String() + "/"
+	for key, w := range m.watches {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if fingerprints[strings.TrimPrefix(key, prefix)] != w.fingerprint {
+			w.cancel()
+			delete(m.watches, key)
+		}
+	}
+
+	// Not started yet, watches are created on the next reconcile
+	if m.ctx == nil {
+		return
+	}
+
+	for backendName, c := range desired {
+		key := consulWatchKey(nn, backendName)
+		if _, ok := m.watches[key]; ok {
+			continue
+		}
+
+		watchCtx, cancel := context.WithCancel(m.ctx)
+		w := &consulWatch{fingerprint: fingerprints[backendName], cancel: cancel}
+		m.watches[key] = w
+		go m.run(watchCtx, w, c, paths[backendName], nn)
+	}
+}
+
+// run performs blocking queries until the watch is cancelled
+func (m *consulWatchManager) run(
+	ctx context.Context,
+	w *consulWatch,
+	c *consulClient,
+	path string,
+	nn types.NamespacedName,
+) {
+	logger := log.FromContext(ctx).WithValues("terraformoutputs", nn, "path", path)
+
+	var index uint64
+	for {
+		pair, newIndex, err := c.getKV(ctx, path, index, consulBlockingWait)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error(err, "Consul blocking query failed, retrying")
+			index = 0
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(consulWatchRetryInterval):
+			}
+			continue
+		}
+
+		// Reset the index if it goes backwards, e.g. after a Consul snapshot restore
+		if newIndex < index {
+			index = 0
+			continue
+		}
+		index = newIndex
+
+		var modifyIndex uint64
+		if pair != nil {
+			modifyIndex = pair.ModifyIndex
+		}
+
+		previous := w.modifyIndex.Swap(modifyIndex)
+		if previous != 0 && previous != modifyIndex {
+			logger.Info("Consul state changed, triggering reconcile", "modifyIndex", modifyIndex)
+			select {
+			case m.events <- event.GenericEvent{Object: &outputsv1alpha1.TerraformOutputs{
+				ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+			}}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// syncConsulWatches makes sure every Consul backend of the resource has a running blocking query.
+// Clients are only created for watches that are new or whose configuration changed. A watch whose
+// new configuration cannot be read or used keeps running until the next reconcile.
+func (r *TerraformOutputsReconciler) syncConsulWatches(
+	ctx context.Context,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+) {
+	if r.consulWatches == nil {
+		return
+	}
+
+	nn := client.ObjectKeyFromObject(tfOutputs)
+	desired := make(map[string]*consulClient)
+	paths := make(map[string]string)
+	fingerprints := make(map[string]string)
+
+	// keepRunning keeps the running watch of a backend rather than stopping it over an error
+	keepRunning := func(backendName string) {
+		if fingerprint, ok := r.consulWatches.fingerprint(nn, backendName); ok {
+			fingerprints[backendName] = fingerprint
+		}
+	}
+
+	for _, backend := range tfOutputs.Spec.Backends {
+		if backend.Consul == nil {
+			continue
+		}
+
+		token, tls, err := r.readConsulCredentials(ctx, *backend.Consul, tfOutputs.Namespace)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to read Consul credentials for watch", "backend", backend.Name)
+			keepRunning(backend.Name)
+			continue
+		}
+
+		fingerprint := consulWatchFingerprint(*backend.Consul, token, tls)
+		if r.consulWatches.running(nn, backend.Name, fingerprint) {
+			fingerprints[backend.Name] = fingerprint
+			continue
+		}
+
+		c, err := r.consulClientFor(*backend.Consul, token, tls, tfOutputs.Namespace, tfOutputs.Name)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to create Consul client for watch", "backend", backend.Name)
+			keepRunning(backend.Name)
+			continue
+		}
+
+		fingerprints[backend.Name] = fingerprint
+		desired[backend.Name] = c
+		paths[backend.Name] = backend.Consul.Path
+	}
+
+	r.consulWatches.sync(nn, desired, paths, fingerprints)
+}
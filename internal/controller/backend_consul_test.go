@@ -0,0 +1,221 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// mockConsulKV is a single key Consul KV store supporting blocking queries
+type mockConsulKV struct {
+	mu          sync.Mutex
+	changed     chan struct{}
+	modifyIndex uint64
+	value       []byte
+}
+
+func (m *mockConsulKV) set(value []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.modifyIndex++
+	m.value = value
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+func (m *mockConsulKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Consul-Token") != "consul-token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.URL.Path != "/v1/kv/terraform/network" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	m.mu.Lock()
+	changed := m.changed
+	index := m.modifyIndex
+	m.mu.Unlock()
+
+	// Block until the key changes when the client already has the current index
+	if requested, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); requested >= index {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(m.modifyIndex, 10))
+	body, _ := json.Marshal([]consulKVPair{{Key: "terraform/network", Value: m.value, ModifyIndex: m.modifyIndex}})
+	_, err := w.Write(body)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+var _ = Describe("Consul backend", func() {
+	It("should escape each segment of the key", func() {
+		var requestPath, datacenter string
+		mockConsulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestPath = r.URL.EscapedPath()
+			datacenter = r.URL.Query().Get("dc")
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		DeferCleanup(mockConsulServer.Close)
+
+		c := &consulClient{baseURL: mockConsulServer.URL, datacenter: "dc1", httpClient: mockConsulServer.Client()}
+		pair, _, err := c.getKV(context.Background(), "/terraform/my app?env=prod#1", 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pair).To(BeNil())
+		Expect(requestPath).To(Equal("/v1/kv/terraform/my%20app%3Fenv=prod%231"))
+		Expect(datacenter).To(Equal("dc1"))
+	})
+
+	Context("When reconciling a resource with a consul backend", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+		var kv *mockConsulKV
+
+		BeforeEach(func() {
+			kv = &mockConsulKV{changed: make(chan struct{})}
+			kv.set(gzipState(`{"outputs":{"subnet_id":{"value":"subnet-1","sensitive":false}}}`))
+			mockConsulServer := httptest.NewServer(kv)
+			DeferCleanup(func() {
+				mockConsulServer.CloseClientConnections()
+				mockConsulServer.Close()
+			})
+
+			resource = newTestTerraformOutputs("test-consul-resource", outputsv1alpha1.BackendSpec{
+				Name: "consul",
+				Consul: &outputsv1alpha1.ConsulSpec{
+					Address: mockConsulServer.Listener.Addr().String(),
+					Path:    "terraform/network",
+					TokenSecretRef: &outputsv1alpha1.SecretKeyReference{
+						Name: "consul-token",
+						Key:  "token",
+					},
+				},
+			})
+			createTestObjects(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "consul-token",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{"token": []byte("consul-token")},
+			}, resource)
+		})
+
+		// startConsulWatches returns a reconciler with a running Consul watch manager
+		startConsulWatches := func() *TerraformOutputsReconciler {
+			watchCtx, stopWatches := context.WithCancel(ctx)
+			DeferCleanup(stopWatches)
+
+			controllerReconciler := newTestReconciler()
+			controllerReconciler.consulWatches = newConsulWatchManager()
+			go func() {
+				defer GinkgoRecover()
+				Expect(controllerReconciler.consulWatches.Start(watchCtx)).To(Succeed())
+			}()
+			Eventually(func() bool {
+				controllerReconciler.consulWatches.mu.Lock()
+				defer controllerReconciler.consulWatches.mu.Unlock()
+				return controllerReconciler.consulWatches.ctx != nil
+			}).Should(BeTrue())
+
+			return controllerReconciler
+		}
+
+		It("should sync gzipped state and trigger a reconcile from the blocking query", func() {
+			controllerReconciler := startConsulWatches()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("subnet_id", "subnet-1"))
+			Expect(resource.Annotations).To(HaveKeyWithValue(ModifyIndexAnnotationPrefix+"consul", "1"))
+
+			By("waiting for the blocking query to observe the current index")
+			Eventually(func() bool {
+				_, ok := controllerReconciler.consulWatches.modifyIndex(client.ObjectKeyFromObject(resource), "consul")
+				return ok
+			}).Should(BeTrue())
+
+			By("changing the state in Consul")
+			kv.set([]byte(`{"outputs":{"subnet_id":{"value":"subnet-2","sensitive":false}}}`))
+
+			var evt event.GenericEvent
+			Eventually(controllerReconciler.consulWatches.events).Should(Receive(&evt))
+			Expect(evt.Object.GetName()).To(Equal(resource.Name))
+			Expect(controllerReconciler.hasWatchedBackendChanges(ctx, resource)).To(BeTrue())
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("subnet_id", "subnet-2"))
+		})
+
+		It("should only restart the watch when its configuration changes", func() {
+			controllerReconciler := startConsulWatches()
+			watchKey := consulWatchKey(client.ObjectKeyFromObject(resource), "consul")
+			currentWatch := func() *consulWatch {
+				controllerReconciler.consulWatches.mu.Lock()
+				defer controllerReconciler.consulWatches.mu.Unlock()
+				return controllerReconciler.consulWatches.watches[watchKey]
+			}
+
+			controllerReconciler.syncConsulWatches(ctx, resource)
+			watch := currentWatch()
+			Expect(watch).NotTo(BeNil())
+
+			By("Keeping the running watch on every reconcile")
+			for range 3 {
+				controllerReconciler.syncConsulWatches(ctx, resource)
+			}
+			Expect(currentWatch()).To(BeIdenticalTo(watch))
+			Expect(controllerReconciler.httpTransports.entries).To(HaveLen(1))
+
+			By("Restarting the watch when the token changes")
+			tokenSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "consul-token", Namespace: "default"}, tokenSecret)).
+				To(Succeed())
+			tokenSecret.Data["token"] = []byte("rotated-token")
+			Expect(k8sClient.Update(ctx, tokenSecret)).To(Succeed())
+
+			controllerReconciler.syncConsulWatches(ctx, resource)
+			Expect(currentWatch()).NotTo(BeIdenticalTo(watch))
+			Expect(controllerReconciler.httpTransports.entries).To(HaveLen(1))
+
+			By("Keeping the running watch when the token cannot be read")
+			watch = currentWatch()
+			Expect(k8sClient.Delete(ctx, tokenSecret)).To(Succeed())
+			controllerReconciler.syncConsulWatches(ctx, resource)
+			Expect(currentWatch()).To(BeIdenticalTo(watch))
+		})
+	})
+})
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// readFileState reads the state of a file backend, either from the state directory or from a ConfigMap
+func (r *TerraformOutputsReconciler) readFileState(
+	ctx context.Context,
+	fileSpec outputsv1alpha1.FileSpec,
+	namespace string,
+) ([]byte, error) {
+	if (fileSpec.Path == "") == (fileSpec.ConfigMapRef == nil) {
+		return nil, fmt.Errorf("exactly one of path or configMapRef must be specified")
+	}
+
+	if fileSpec.ConfigMapRef != nil {
+		configMap := &corev1.ConfigMap{}
+		key := types.NamespacedName{Name: fileSpec.ConfigMapRef.Name, Namespace: namespace}
+		if err := r.Get(ctx, key, configMap); err != nil {
+			return nil, fmt.Errorf("failed to get state ConfigMap %s: %w", key, err)
+		}
+		if data, ok := configMap.Data[fileSpec.ConfigMapRef.Key]; ok {
+			return []byte(data), nil
+		}
+		if data, ok := configMap.BinaryData[fileSpec.ConfigMapRef.Key]; ok {
+			return data, nil
+		}
+		return nil, fmt.Errorf("state ConfigMap %s has no %q key", key, fileSpec.ConfigMapRef.Key)
+	}
+
+	if r.StateDir == "" {
+		return nil, fmt.Errorf("file paths are disabled, start the controller with --state-dir to enable them")
+	}
+
+	// os.Root rejects paths escaping the state directory, including through symlinks
+	root, err := os.OpenRoot(r.StateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state directory: %w", err)
+	}
+	defer func() {
+		_ = root.Close()
+	}()
+
+	file, err := root.Open(fileSpec.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return io.ReadAll(file)
+}
+
+// getFileStateHash gets the SHA-256 content hash of a file backend state
+func (r *TerraformOutputsReconciler) getFileStateHash(
+	ctx context.Context,
+	fileSpec outputsv1alpha1.FileSpec,
+	namespace string,
+) (string, error) {
+	body, err := r.readFileState(ctx, fileSpec, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// fetchTerraformOutputsFromFile fetches outputs from a single file backend
+func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromFile(
+	ctx context.Context,
+	fileSpec outputsv1alpha1.FileSpec,
+	backendName string,
+	namespace string,
+) (map[string]interface{}, map[string]bool, error) {
+	logger := log.FromContext(ctx)
+
+	logger.Info("Reading Terraform state file", "backend", backendName, "path", fileSpec.Path)
+
+	body, err := r.readFileState(ctx, fileSpec, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parseTerraformOutputs(ctx, body)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+var _ = Describe("File backend", func() {
+	// The sample state fixtures double as the state directory
+	samplesDir := filepath.Join("..", "..", "config", "samples")
+
+	It("should reject paths outside of the state directory", func() {
+		controllerReconciler := &TerraformOutputsReconciler{StateDir: samplesDir}
+
+		_, err := controllerReconciler.readFileState(context.Background(), outputsv1alpha1.FileSpec{
+			Path: "../../go.mod",
+		}, "default")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject paths when no state directory is configured", func() {
+		controllerReconciler := &TerraformOutputsReconciler{}
+
+		_, err := controllerReconciler.readFileState(context.Background(), outputsv1alpha1.FileSpec{
+			Path: "test-terraform.tfstate",
+		}, "default")
+		Expect(err).To(MatchError(ContainSubstring("--state-dir")))
+	})
+
+	Context("When reconciling a resource with file backends", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+
+		BeforeEach(func() {
+			state, err := os.ReadFile(filepath.Join(samplesDir, "test-terraform-one.tfstate"))
+			Expect(err).NotTo(HaveOccurred())
+
+			configMapBackend := newTestFileBackend("file-state")
+			configMapBackend.Name = "configmap"
+			configMapBackend.KeyPrefix = "shared_"
+			resource = newTestTerraformOutputs("test-file-resource",
+				outputsv1alpha1.BackendSpec{
+					Name: "mounted",
+					File: &outputsv1alpha1.FileSpec{
+						Path: "test-terraform.tfstate",
+					},
+				},
+				configMapBackend,
+			)
+			createTestObjects(ctx, newTestStateConfigMap("file-state", string(state)), resource)
+		})
+
+		It("should sync outputs from the state directory and a ConfigMap", func() {
+			controllerReconciler := newTestReconciler()
+			controllerReconciler.StateDir = samplesDir
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			configMap := syncedConfigMap(ctx, resource)
+			Expect(configMap.Data).To(HaveKey("vpc_id"))
+			Expect(configMap.Data).To(HaveKey("subnet_ids"))
+			Expect(configMap.Data).To(HaveKey("shared_some_other_vpc_id"))
+			Expect(syncedSecret(ctx, resource).Data).To(HaveKey("database_password"))
+
+			Expect(resource.Annotations).To(HaveKey(FileHashAnnotationPrefix + "mounted"))
+			Expect(resource.Annotations).To(HaveKey(FileHashAnnotationPrefix + "configmap"))
+
+			By("Reporting no changes while the state is unchanged")
+			changed, _, err := controllerReconciler.checkBackendChanges(ctx, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeFalse())
+
+			By("Removing the annotations of renamed backends")
+			resource.Annotations[FileHashAnnotationPrefix+"0"] = "stale"
+			_, versions, err := controllerReconciler.checkBackendChanges(ctx, resource)
+			Expect(err).NotTo(HaveOccurred())
+			controllerReconciler.updateETagAnnotations(resource, versions)
+			Expect(resource.Annotations).NotTo(HaveKey(FileHashAnnotationPrefix + "0"))
+			Expect(resource.Annotations).To(HaveKey(FileHashAnnotationPrefix + "mounted"))
+		})
+	})
+})
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/option"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// gcsObjectName resolves the state object path the same way as the Terraform gcs backend
+func gcsObjectName(gcsSpec outputsv1alpha1.GCSSpec) string {
+	if gcsSpec.Object != "" {
+		return gcsSpec.Object
+	}
+
+	workspace := gcsSpec.Workspace
+	if workspace == "" {
+		workspace = "default"
+	}
+
+	return path.Join(gcsSpec.Prefix, workspace+".tfstate")
+}
+
+// newGCSClient returns a cached GCS client using either the referenced service account key
+// or the ambient credentials (workload identity / application default credentials)
+func (r *TerraformOutputsReconciler) newGCSClient(
+	ctx context.Context,
+	gcsSpec outputsv1alpha1.GCSSpec,
+	namespace string,
+) (*storage.Client, error) {
+	var opts []option.ClientOption
+
+	if gcsSpec.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(gcsSpec.Endpoint))
+	}
+
+	// The credentials are part of the cache key, so clients using different tenants'
+	// credentials, or rotated credentials, are never shared
+	credentials := "default"
+	if gcsSpec.CredentialsSecretRef != nil {
+		credentialsJSON, err := r.readSecretKey(ctx, namespace, *gcsSpec.CredentialsSecretRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GCS credentials: %w", err)
+		}
+		opts = append(opts, option.WithCredentialsJSON(credentialsJSON))
+		credentialsHash := sha256.Sum256(credentialsJSON)
+		credentials = hex.EncodeToString(credentialsHash[:])
+	} else if gcsSpec.Endpoint != "" {
+		// Custom endpoints without explicit credentials are assumed to be
+		// emulators such as fake-gcs-server which don't require authentication
+		opts = append(opts, option.WithoutAuthentication())
+		credentials = "none"
+	}
+
+	cacheKey := strings.Join([]string{credentials, gcsSpec.Endpoint}, "|")
+	return r.gcsClients.get("gcs", cacheKey, func() (*storage.Client, error) {
+		// The client refreshes its tokens with this context after the reconcile returns
+		client, err := storage.NewClient(context.WithoutCancel(ctx), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCS client: %w", err)
+		}
+		return client, nil
+	})
+}
+
+// getGCSObjectGeneration gets the generation and CRC32C of a GCS object without downloading it
+func (r *TerraformOutputsReconciler) getGCSObjectGeneration(
+	ctx context.Context,
+	gcsSpec outputsv1alpha1.GCSSpec,
+	namespace, name string,
+) (string, error) {
+	client, err := r.newGCSClient(ctx, gcsSpec, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	gcsLabels := prometheus.Labels{
+		"namespace":    namespace,
+		"name":         name,
+		"backend_type": "gcs",
+		"operation":    "GetObjectAttrs",
+	}
+
+	attrs, err := client.Bucket(gcsSpec.Bucket).Object(gcsObjectName(gcsSpec)).Attrs(ctx)
+	if err != nil {
+		gcsLabels["result"] = resultError
+		backendRequestsTotal.With(gcsLabels).Inc()
+		return "", fmt.Errorf("failed to get GCS object metadata: %w", err)
+	}
+
+	gcsLabels["result"] = resultSuccess
+	backendRequestsTotal.With(gcsLabels).Inc()
+
+	// The generation changes on every overwrite, the CRC32C covers the content itself
+	return fmt.Sprintf("%d-%08x", attrs.Generation, attrs.CRC32C), nil
+}
+
+// fetchTerraformOutputsFromGCS fetches outputs from a single GCS backend
+func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromGCS(
+	ctx context.Context,
+	gcsSpec outputsv1alpha1.GCSSpec,
+	backendName string,
+	namespace, name string,
+) (map[string]interface{}, map[string]bool, error) {
+	logger := log.FromContext(ctx)
+
+	client, err := r.newGCSClient(ctx, gcsSpec, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objectName := gcsObjectName(gcsSpec)
+
+	// Download state file
+	logger.Info(
+		"Downloading Terraform state",
+		"backend",
+		backendName,
+		"bucket",
+		gcsSpec.Bucket,
+		"object",
+		objectName,
+	)
+
+	gcsLabels := prometheus.Labels{
+		"namespace":    namespace,
+		"name":         name,
+		"backend_type": "gcs",
+		"operation":    "GetObject",
+	}
+
+	reader, err := client.Bucket(gcsSpec.Bucket).Object(objectName).NewReader(ctx)
+	if err != nil {
+		gcsLabels["result"] = resultError
+		backendRequestsTotal.With(gcsLabels).Inc()
+		return nil, nil, fmt.Errorf("failed to download state file: %w", err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			logger.Error(err, "Failed to close GCS object reader")
+		}
+	}()
+
+	gcsLabels["result"] = resultSuccess
+	backendRequestsTotal.With(gcsLabels).Inc()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read state file body: %w", err)
+	}
+
+	return parseTerraformOutputs(ctx, body)
+}
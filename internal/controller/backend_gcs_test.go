@@ -0,0 +1,110 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+var _ = Describe("GCS backend", func() {
+	It("should resolve state object names like the terraform gcs backend", func() {
+		Expect(gcsObjectName(outputsv1alpha1.GCSSpec{Prefix: "network"})).
+			To(Equal("network/default.tfstate"))
+		Expect(gcsObjectName(outputsv1alpha1.GCSSpec{Prefix: "network", Workspace: "prod"})).
+			To(Equal("network/prod.tfstate"))
+		Expect(gcsObjectName(outputsv1alpha1.GCSSpec{Prefix: "network", Object: "custom.tfstate"})).
+			To(Equal("custom.tfstate"))
+	})
+
+	Context("When reconciling a resource with a GCS backend", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+		var generation string
+
+		BeforeEach(func() {
+			generation = "1"
+
+			stateBytes, _ := json.Marshal(map[string]interface{}{
+				"outputs": map[string]interface{}{
+					"network_name": map[string]interface{}{
+						"value":     "shared-vpc",
+						"sensitive": false,
+					},
+				},
+			})
+
+			// Mock the subset of the GCS JSON and XML APIs used by the storage client,
+			// the same way a local fake GCS server would respond
+			mockGCSServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					switch r.URL.Path {
+					case "/storage/v1/b/test-bucket/o/network/prod.tfstate":
+						w.Header().Set("Content-Type", "application/json")
+						_, err := w.Write([]byte(`{"bucket":"test-bucket","name":"network/prod.tfstate",` +
+							`"generation":"` + generation + `","crc32c":"AAAAAQ=="}`))
+						Expect(err).NotTo(HaveOccurred())
+					case "/test-bucket/network/prod.tfstate":
+						w.Header().Set("X-Goog-Generation", generation)
+						_, err := w.Write(stateBytes)
+						Expect(err).NotTo(HaveOccurred())
+					default:
+						w.WriteHeader(http.StatusNotFound)
+					}
+				}),
+			)
+			DeferCleanup(mockGCSServer.Close)
+
+			resource = newTestTerraformOutputs("test-gcs-resource", outputsv1alpha1.BackendSpec{
+				Name: "gcs",
+				GCS: &outputsv1alpha1.GCSSpec{
+					Bucket:    "test-bucket",
+					Prefix:    "network",
+					Workspace: "prod",
+					Endpoint:  mockGCSServer.URL + "/storage/v1/",
+				},
+			})
+			createTestObjects(ctx, resource)
+		})
+
+		It("should sync outputs and track the object generation", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("network_name", "shared-vpc"))
+			Expect(resource.Annotations).To(
+				HaveKeyWithValue(GCSGenerationAnnotationPrefix+"gcs", "1-00000001"),
+			)
+
+			By("detecting a new object generation")
+			generation = "2"
+			hasChanges, _, err := controllerReconciler.checkBackendChanges(ctx, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hasChanges).To(BeTrue())
+
+			By("Sharing one client between the version checks and downloads")
+			Expect(controllerReconciler.gcsClients.entries).To(HaveLen(1))
+		})
+	})
+})
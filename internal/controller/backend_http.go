@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const (
+	// Prefixes of the stored validator, telling which conditional request header to send
+	httpValidatorETag         = "etag:"
+	httpValidatorLastModified = "last-modified:"
+	httpValidatorSHA256       = "sha256:"
+
+	// httpStateLockedRetryInterval is the delay before syncing again a state that was locked
+	httpStateLockedRetryInterval = 30 * time.Second
+)
+
+// httpStateLockedError is returned when the server refuses to serve a state with 423 Locked
+// while a Terraform run holds its lock, so the reconciler defers the sync instead of failing.
+//
+// The lock is never acquired for reading: holding it would make concurrent Terraform runs
+// fail, and Terraform uploads a state in a single request, so servers that keep serving the
+// state while it is locked, like GitLab, only ever return the last complete state.
+type httpStateLockedError struct {
+	address string
+}
+
+func (e *httpStateLockedError) Error() string {
+	return fmt.Sprintf("state %s is locked", e.address)
+}
+
+// asHTTPStateLockedError returns the httpStateLockedError wrapped by err, if any
+func asHTTPStateLockedError(err error) *httpStateLockedError {
+	var lockedErr *httpStateLockedError
+	if errors.As(err, &lockedErr) {
+		return lockedErr
+	}
+	return nil
+}
+
+// httpStateCache holds the HTTP states downloaded during a reconcile, so the state downloaded by
+// the change check is reused by the fetch, and later checks send the latest validator
+type httpStateCache struct {
+	mu     sync.Mutex
+	states map[string]httpCachedState
+}
+
+// httpCachedState is a downloaded HTTP state and its validator
+type httpCachedState struct {
+	validator string
+	body      []byte
+}
+
+type httpStateCacheContextKey struct{}
+
+// withHTTPStateCache returns a context in which HTTP states are downloaded at most once
+func withHTTPStateCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, httpStateCacheContextKey{}, &httpStateCache{})
+}
+
+// httpStateCacheFrom returns the HTTP state cache of the context, or nil when there is none
+func httpStateCacheFrom(ctx context.Context) *httpStateCache {
+	cache, _ := ctx.Value(httpStateCacheContextKey{}).(*httpStateCache)
+	return cache
+}
+
+// get returns the state last downloaded from the address
+func (c *httpStateCache) get(address string) (httpCachedState, bool) {
+	if c == nil {
+		return httpCachedState{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.states[address]
+	return state, ok
+}
+
+// put records the state downloaded from the address
+func (c *httpStateCache) put(address string, state httpCachedState) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.states == nil {
+		c.states = make(map[string]httpCachedState)
+	}
+	c.states[address] = state
+}
+
+// httpStateRequest performs a GET request against the state address. When a validator from a
+// previous request is given, a conditional GET is sent and a 304 response returns a nil body.
+func (r *TerraformOutputsReconciler) httpStateRequest(
+	ctx context.Context,
+	httpSpec outputsv1alpha1.HTTPSpec,
+	validator, operation string,
+	namespace, name string,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, httpSpec.Address, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %w", err)
+	}
+
+	if httpSpec.UsernameSecretRef != nil || httpSpec.PasswordSecretRef != nil {
+		var username, password []byte
+		if httpSpec.UsernameSecretRef != nil {
+			if username, err = r.readSecretKey(ctx, namespace, *httpSpec.UsernameSecretRef); err != nil {
+				return nil, fmt.Errorf("failed to read username: %w", err)
+			}
+		}
+		if httpSpec.PasswordSecretRef != nil {
+			if password, err = r.readSecretKey(ctx, namespace, *httpSpec.PasswordSecretRef); err != nil {
+				return nil, fmt.Errorf("failed to read password: %w", err)
+			}
+		}
+		req.SetBasicAuth(strings.TrimSpace(string(username)), strings.TrimSpace(string(password)))
+	}
+
+	switch {
+	case strings.HasPrefix(validator, httpValidatorETag):
+		req.Header.Set("If-None-Match", strings.TrimPrefix(validator, httpValidatorETag))
+	case strings.HasPrefix(validator, httpValidatorLastModified):
+		req.Header.Set("If-Modified-Since", strings.TrimPrefix(validator, httpValidatorLastModified))
+	}
+
+	transport, err := r.httpTransport(ctx, namespace, httpSpec.TLS)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{Timeout: 30 * time.Second, Transport: transport}
+
+	httpLabels := prometheus.Labels{
+		"namespace":    namespace,
+		"name":         name,
+		"backend_type": "http",
+		"operation":    operation,
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		httpLabels["result"] = resultError
+		backendRequestsTotal.With(httpLabels).Inc()
+		return nil, fmt.Errorf("failed to request state: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotModified {
+		_ = resp.Body.Close()
+		httpLabels["result"] = resultError
+		backendRequestsTotal.With(httpLabels).Inc()
+		if resp.StatusCode == http.StatusLocked {
+			return nil, &httpStateLockedError{address: httpSpec.Address}
+		}
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, httpSpec.Address)
+	}
+
+	httpLabels["result"] = resultSuccess
+	backendRequestsTotal.With(httpLabels).Inc()
+
+	return resp, nil
+}
+
+// httpStateValidator derives the validator of a state response, preferring the ETag over
+// Last-Modified and falling back to a content hash when the server sends neither
+func httpStateValidator(resp *http.Response, body []byte) string {
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return httpValidatorETag + etag
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		return httpValidatorLastModified + lastModified
+	}
+	sum := sha256.Sum256(body)
+	return httpValidatorSHA256 + hex.EncodeToString(sum[:])
+}
+
+// getHTTPStateValidator checks the HTTP state with a conditional GET, so unchanged
+// state is not downloaded again. The stored validator is returned when the server
+// answers 304 Not Modified. A downloaded state is kept for the rest of the reconcile,
+// so fetching it does not download it again, and checking it again sends its validator.
+func (r *TerraformOutputsReconciler) getHTTPStateValidator(
+	ctx context.Context,
+	httpSpec outputsv1alpha1.HTTPSpec,
+	storedValidator string,
+	namespace, name string,
+) (string, error) {
+	cache := httpStateCacheFrom(ctx)
+	if cached, ok := cache.get(httpSpec.Address); ok {
+		storedValidator = cached.validator
+	}
+
+	resp, err := r.httpStateRequest(ctx, httpSpec, storedValidator, "ConditionalGet", namespace, name)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return storedValidator, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read state body: %w", err)
+	}
+
+	validator := httpStateValidator(resp, body)
+	cache.put(httpSpec.Address, httpCachedState{validator: validator, body: body})
+
+	return validator, nil
+}
+
+// fetchTerraformOutputsFromHTTP fetches outputs from a single HTTP backend, reusing the
+// state downloaded by the change check of the same reconcile
+func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromHTTP(
+	ctx context.Context,
+	httpSpec outputsv1alpha1.HTTPSpec,
+	backendName string,
+	namespace, name string,
+) (map[string]interface{}, map[string]bool, error) {
+	logger := log.FromContext(ctx)
+
+	cache := httpStateCacheFrom(ctx)
+	if cached, ok := cache.get(httpSpec.Address); ok && cached.body != nil {
+		logger.Info("Reusing downloaded Terraform state", "backend", backendName, "address", httpSpec.Address)
+		return parseTerraformOutputs(ctx, cached.body)
+	}
+
+	logger.Info("Downloading Terraform state", "backend", backendName, "address", httpSpec.Address)
+
+	resp, err := r.httpStateRequest(ctx, httpSpec, "", "Get", namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "Failed to close HTTP response body")
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read state body: %w", err)
+	}
+	cache.put(httpSpec.Address, httpCachedState{validator: httpStateValidator(resp, body), body: body})
+
+	return parseTerraformOutputs(ctx, body)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+var _ = Describe("HTTP backend", func() {
+	Context("When reconciling a resource with an HTTP backend", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+		var downloads, notModified atomic.Int32
+		var locked atomic.Bool
+
+		BeforeEach(func() {
+			downloads.Store(0)
+			notModified.Store(0)
+			locked.Store(false)
+
+			// Mock a GitLab-style state endpoint supporting conditional requests
+			mockStateServer := httptest.NewTLSServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					username, password, ok := r.BasicAuth()
+					if !ok || username != "gitlab-ci-token" || password != "glpat-test" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					if r.URL.Path != "/api/v4/projects/42/terraform/state/production" {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					if locked.Load() {
+						w.WriteHeader(http.StatusLocked)
+						return
+					}
+
+					w.Header().Set("ETag", `"state-v1"`)
+					if r.Header.Get("If-None-Match") == `"state-v1"` {
+						notModified.Add(1)
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+
+					downloads.Add(1)
+					_, err := w.Write([]byte(`{
+						"version": 4,
+						"outputs": {
+							"cluster_endpoint": {"value": "https://k8s.example.com", "sensitive": false},
+							"registry_password": {"value": "s3cr3t", "sensitive": true}
+						}
+					}`))
+					Expect(err).NotTo(HaveOccurred())
+				}),
+			)
+			DeferCleanup(mockStateServer.Close)
+
+			resource = newTestTerraformOutputs("test-http-resource", outputsv1alpha1.BackendSpec{
+				Name: "http",
+				HTTP: &outputsv1alpha1.HTTPSpec{
+					Address: mockStateServer.URL + "/api/v4/projects/42/terraform/state/production",
+					UsernameSecretRef: &outputsv1alpha1.SecretKeyReference{
+						Name: "gitlab-state",
+						Key:  "username",
+					},
+					PasswordSecretRef: &outputsv1alpha1.SecretKeyReference{
+						Name: "gitlab-state",
+						Key:  "token",
+					},
+					TLS: &outputsv1alpha1.TLSSpec{InsecureSkipVerify: true},
+				},
+			})
+			createTestObjects(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "gitlab-state",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{
+					"username": []byte("gitlab-ci-token"),
+					"token":    []byte("glpat-test"),
+				},
+			}, resource)
+		})
+
+		It("should sync outputs and skip downloading unchanged state", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("cluster_endpoint", "https://k8s.example.com"))
+			Expect(string(syncedSecret(ctx, resource).Data["registry_password"])).To(Equal("s3cr3t"))
+			Expect(resource.Annotations).
+				To(HaveKeyWithValue(HTTPValidatorAnnotationPrefix+"http", httpValidatorETag+`"state-v1"`))
+
+			By("Downloading the state once and sharing one transport between the requests")
+			Expect(downloads.Load()).To(Equal(int32(1)))
+			Expect(controllerReconciler.httpTransports.entries).To(HaveLen(1))
+
+			By("Checking the unchanged state with a conditional request")
+			downloadsBefore := downloads.Load()
+			changed, _, err := controllerReconciler.checkBackendChanges(ctx, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeFalse())
+			Expect(downloads.Load()).To(Equal(downloadsBefore))
+			Expect(notModified.Load()).To(BeNumerically(">", 0))
+		})
+
+		It("should defer the sync while the state is locked", func() {
+			controllerReconciler := newTestReconciler()
+			locked.Store(true)
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(resource),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(httpStateLockedRetryInterval))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(resource), resource)).To(Succeed())
+			Expect(resource.Status.SyncStatus).NotTo(Equal(statusFailed))
+			Expect(resource.Annotations).NotTo(HaveKey(HTTPValidatorAnnotationPrefix + "http"))
+
+			By("Syncing once the lock is released")
+			locked.Store(false)
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("cluster_endpoint", "https://k8s.example.com"))
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const (
+	// stateSecretLabel is set by the Terraform kubernetes backend on every state Secret
+	stateSecretLabel = "tfstate"
+	// stateSecretDataKey is the Secret key holding the gzipped state
+	stateSecretDataKey = "tfstate"
+)
+
+// stateSecretKey resolves the state Secret written by the Terraform kubernetes backend
+func stateSecretKey(k8sSpec outputsv1alpha1.KubernetesSpec, namespace string) types.NamespacedName {
+	workspace := k8sSpec.Workspace
+	if workspace == "" {
+		workspace = "default"
+	}
+
+	if k8sSpec.Namespace != "" {
+		namespace = k8sSpec.Namespace
+	}
+
+	return types.NamespacedName{
+		Name:      fmt.Sprintf("tfstate-%s-%s", workspace, k8sSpec.SecretSuffix),
+		Namespace: namespace,
+	}
+}
+
+// resolveStateSecret resolves the state Secret of a kubernetes backend, rejecting Secrets in
+// another namespace than the TerraformOutputs unless AllowCrossNamespaceStateSecrets is set
+func (r *TerraformOutputsReconciler) resolveStateSecret(
+	k8sSpec outputsv1alpha1.KubernetesSpec,
+	namespace string,
+) (types.NamespacedName, error) {
+	secretKey := stateSecretKey(k8sSpec, namespace)
+	if secretKey.Namespace != namespace && !r.AllowCrossNamespaceStateSecrets {
+		return types.NamespacedName{}, fmt.Errorf(
+			"state Secret %s is in another namespace, which requires --allow-cross-namespace-state-secrets",
+			secretKey,
+		)
+	}
+	return secretKey, nil
+}
+
+// getStateSecretResourceVersion gets the resourceVersion of the state Secret
+func (r *TerraformOutputsReconciler) getStateSecretResourceVersion(
+	ctx context.Context,
+	k8sSpec outputsv1alpha1.KubernetesSpec,
+	namespace string,
+) (string, error) {
+	secretKey, err := r.resolveStateSecret(k8sSpec, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		return "", fmt.Errorf("failed to get state Secret %s: %w", secretKey, err)
+	}
+
+	return secret.ResourceVersion, nil
+}
+
+// fetchTerraformOutputsFromKubernetes fetches outputs from a state Secret written by the
+// Terraform kubernetes backend
+func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromKubernetes(
+	ctx context.Context,
+	k8sSpec outputsv1alpha1.KubernetesSpec,
+	backendName string,
+	namespace string,
+) (map[string]interface{}, map[string]bool, error) {
+	logger := log.FromContext(ctx)
+
+	secretKey, err := r.resolveStateSecret(k8sSpec, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Info("Reading Terraform state Secret", "backend", backendName, "secret", secretKey)
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		return nil, nil, fmt.Errorf("failed to get state Secret %s: %w", secretKey, err)
+	}
+
+	compressed, ok := secret.Data[stateSecretDataKey]
+	if !ok {
+		return nil, nil, fmt.Errorf("state Secret %s has no %q key", secretKey, stateSecretDataKey)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress state Secret %s: %w", secretKey, err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			logger.Error(err, "Failed to close gzip reader")
+		}
+	}()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress state Secret %s: %w", secretKey, err)
+	}
+
+	return parseTerraformOutputs(ctx, body)
+}
+
+// findTerraformOutputsForStateSecret maps a Terraform state Secret to the TerraformOutputs
+// resources reading from it
+func (r *TerraformOutputsReconciler) findTerraformOutputsForStateSecret(
+	ctx context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	if obj.GetLabels()[stateSecretLabel] != "true" {
+		return nil
+	}
+
+	var tfOutputsList outputsv1alpha1.TerraformOutputsList
+	if err := r.List(ctx, &tfOutputsList); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list TerraformOutputs for state Secret")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, tfOutputs := range tfOutputsList.Items {
+		for _, backend := range tfOutputs.Spec.Backends {
+			if backend.Kubernetes == nil {
+				continue
+			}
+
+			secretKey, err := r.resolveStateSecret(*backend.Kubernetes, tfOutputs.Namespace)
+			if err == nil && secretKey.Name == obj.GetName() && secretKey.Namespace == obj.GetNamespace() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      tfOutputs.Name,
+						Namespace: tfOutputs.Namespace,
+					},
+				})
+				break
+			}
+		}
+	}
+
+	return requests
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// gzipState compresses a Terraform state the same way as the terraform kubernetes backend
+func gzipState(state string) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(state))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(writer.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+var _ = Describe("Kubernetes backend", func() {
+	It("should only read state Secrets in other namespaces when allowed", func() {
+		k8sSpec := outputsv1alpha1.KubernetesSpec{SecretSuffix: "network", Namespace: "terraform"}
+		controllerReconciler := newTestReconciler()
+
+		_, err := controllerReconciler.resolveStateSecret(k8sSpec, "default")
+		Expect(err).To(MatchError(ContainSubstring("--allow-cross-namespace-state-secrets")))
+		Expect(controllerReconciler.resolveStateSecret(outputsv1alpha1.KubernetesSpec{
+			SecretSuffix: "network",
+			Namespace:    "default",
+		}, "default")).To(Equal(types.NamespacedName{Name: "tfstate-default-network", Namespace: "default"}))
+
+		controllerReconciler.AllowCrossNamespaceStateSecrets = true
+		Expect(controllerReconciler.resolveStateSecret(k8sSpec, "default")).
+			To(Equal(types.NamespacedName{Name: "tfstate-default-network", Namespace: "terraform"}))
+	})
+
+	Context("When reconciling a resource with a kubernetes backend", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+
+		stateSecretName := types.NamespacedName{
+			Name:      "tfstate-default-network",
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			resource = newTestTerraformOutputs("test-kubernetes-resource", outputsv1alpha1.BackendSpec{
+				Name: "kubernetes",
+				Kubernetes: &outputsv1alpha1.KubernetesSpec{
+					SecretSuffix: "network",
+				},
+			})
+			createTestObjects(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      stateSecretName.Name,
+					Namespace: stateSecretName.Namespace,
+					Labels: map[string]string{
+						"tfstate":             "true",
+						"tfstateSecretSuffix": "network",
+						"tfstateWorkspace":    "default",
+					},
+				},
+				Data: map[string][]byte{
+					"tfstate": gzipState(`{"outputs":{"cluster_name":{"value":"prod","sensitive":false}}}`),
+				},
+			}, resource)
+		})
+
+		It("should sync outputs from the gzipped state Secret and react to updates", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("cluster_name", "prod"))
+			Expect(controllerReconciler.hasWatchedBackendChanges(ctx, resource)).To(BeFalse())
+
+			By("updating the state Secret")
+			stateSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, stateSecretName, stateSecret)).To(Succeed())
+			stateSecret.Data["tfstate"] = gzipState(`{"outputs":{"cluster_name":{"value":"prod-2","sensitive":false}}}`)
+			Expect(k8sClient.Update(ctx, stateSecret)).To(Succeed())
+
+			Expect(controllerReconciler.findTerraformOutputsForSecret(ctx, stateSecret)).To(
+				ConsistOf(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)}),
+			)
+			Expect(controllerReconciler.hasWatchedBackendChanges(ctx, resource)).To(BeTrue())
+
+			By("reconciling again within the sync interval")
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("cluster_name", "prod-2"))
+		})
+	})
+})
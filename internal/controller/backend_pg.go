@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const defaultPGSchemaName = "terraform_remote_state"
+
+// pgStateQuery builds a query against the states table of the Terraform pg backend.
+// The selected column is either the state itself or a hash of it.
+func pgStateQuery(pgSpec outputsv1alpha1.PGSpec, column string) string {
+	schemaName := pgSpec.SchemaName
+	if schemaName == "" {
+		schemaName = defaultPGSchemaName
+	}
+
+	return fmt.Sprintf("SELECT %s FROM %s.states WHERE name = $1", column, pq.QuoteIdentifier(schemaName))
+}
+
+// pgWorkspace returns the workspace name used as the states table key
+func pgWorkspace(pgSpec outputsv1alpha1.PGSpec) string {
+	if pgSpec.Workspace == "" {
+		return "default"
+	}
+	return pgSpec.Workspace
+}
+
+// queryPGState runs a single-row query against the states table using the connection
+// string from the referenced Secret
+func (r *TerraformOutputsReconciler) queryPGState(
+	ctx context.Context,
+	pgSpec outputsv1alpha1.PGSpec,
+	column, operation string,
+	namespace, name string,
+) (string, error) {
+	logger := log.FromContext(ctx)
+
+	connStr, err := r.readSecretKey(ctx, namespace, pgSpec.ConnectionStringSecretRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to read connection string: %w", err)
+	}
+
+	db, err := sql.Open("postgres", string(connStr))
+	if err != nil {
+		return "", fmt.Errorf("failed to open PostgreSQL connection: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			logger.Error(err, "Failed to close PostgreSQL connection")
+		}
+	}()
+
+	pgLabels := prometheus.Labels{
+		"namespace":    namespace,
+		"name":         name,
+		"backend_type": "pg",
+		"operation":    operation,
+	}
+
+	var result string
+	err = db.QueryRowContext(ctx, pgStateQuery(pgSpec, column), pgWorkspace(pgSpec)).Scan(&result)
+	if err != nil {
+		pgLabels["result"] = resultError
+		backendRequestsTotal.With(pgLabels).Inc()
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("no state found for workspace %q", pgWorkspace(pgSpec))
+		}
+		return "", fmt.Errorf("failed to query state: %w", err)
+	}
+
+	pgLabels["result"] = resultSuccess
+	backendRequestsTotal.With(pgLabels).Inc()
+
+	return result, nil
+}
+
+// getPGStateHash gets a hash of the stored state without transferring the state itself
+func (r *TerraformOutputsReconciler) getPGStateHash(
+	ctx context.Context,
+	pgSpec outputsv1alpha1.PGSpec,
+	namespace, name string,
+) (string, error) {
+	return r.queryPGState(ctx, pgSpec, "md5(data)", "QueryStateHash", namespace, name)
+}
+
+// fetchTerraformOutputsFromPG fetches outputs from a single pg backend
+func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromPG(
+	ctx context.Context,
+	pgSpec outputsv1alpha1.PGSpec,
+	backendName string,
+	namespace, name string,
+) (map[string]interface{}, map[string]bool, error) {
+	logger := log.FromContext(ctx)
+
+	logger.Info(
+		"Reading Terraform state from PostgreSQL",
+		"backend",
+		backendName,
+		"schema",
+		pgSpec.SchemaName,
+		"workspace",
+		pgWorkspace(pgSpec),
+	)
+
+	state, err := r.queryPGState(ctx, pgSpec, "data", "QueryState", namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parseTerraformOutputs(ctx, []byte(state))
+}
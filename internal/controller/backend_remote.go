@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const (
+	defaultRemoteHostname = "app.terraform.io"
+	remoteAPIMediaType    = "application/vnd.api+json"
+)
+
+// remoteClient is a minimal client for the Terraform Cloud / Enterprise API
+type remoteClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	namespace  string
+	name       string
+}
+
+// remoteWorkspace is the subset of the workspace resource used by the controller
+type remoteWorkspace struct {
+	Data struct {
+		ID            string `json:"id"`
+		Relationships struct {
+			CurrentStateVersion struct {
+				Data *struct {
+					ID string `json:"id"`
+				} `json:"data"`
+			} `json:"current-state-version"`
+		} `json:"relationships"`
+	} `json:"data"`
+}
+
+// remoteStateVersionOutput is a single state version output resource
+type remoteStateVersionOutput struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Name      string      `json:"name"`
+		Sensitive bool        `json:"sensitive"`
+		Value     interface{} `json:"value"`
+	} `json:"attributes"`
+}
+
+// remoteStateVersionOutputs is a page of state version outputs
+type remoteStateVersionOutputs struct {
+	Data  []remoteStateVersionOutput `json:"data"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+// resolveLink resolves a pagination link against the API base URL. Links to another host are
+// rejected, so the API token is never sent outside of the configured hostname.
+func (c *remoteClient) resolveLink(link string) (string, error) {
+	if link == "" {
+		return "", nil
+	}
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := base.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	if resolved.Scheme != base.Scheme || resolved.Host != base.Host {
+		return "", fmt.Errorf("link %s does not point to %s", link, base.Host)
+	}
+	return resolved.String(), nil
+}
+
+// remoteAPIBaseURL returns the API base URL for the configured hostname
+func remoteAPIBaseURL(remoteSpec outputsv1alpha1.RemoteSpec) string {
+	hostname := remoteSpec.Hostname
+	if hostname == "" {
+		hostname = defaultRemoteHostname
+	}
+	if !strings.Contains(hostname, "://") {
+		hostname = "https://" + hostname
+	}
+	return strings.TrimSuffix(hostname, "/") + "/api/v2"
+}
+
+// newRemoteClient creates a Terraform Cloud API client using the token from the referenced Secret
+func (r *TerraformOutputsReconciler) newRemoteClient(
+	ctx context.Context,
+	remoteSpec outputsv1alpha1.RemoteSpec,
+	namespace, name string,
+) (*remoteClient, error) {
+	token, err := r.readSecretKey(ctx, namespace, remoteSpec.TokenSecretRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API token: %w", err)
+	}
+
+	return &remoteClient{
+		baseURL:    remoteAPIBaseURL(remoteSpec),
+		token:      strings.TrimSpace(string(token)),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		namespace:  namespace,
+		name:       name,
+	}, nil
+}
+
+// get performs an authenticated GET request and decodes the JSON:API response into out
+func (c *remoteClient) get(ctx context.Context, operation, requestURL string, out interface{}) error {
+	remoteLabels := prometheus.Labels{
+		"namespace":    c.namespace,
+		"name":         c.name,
+		"backend_type": "remote",
+		"operation":    operation,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", remoteAPIMediaType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		remoteLabels["result"] = resultError
+		backendRequestsTotal.With(remoteLabels).Inc()
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		remoteLabels["result"] = resultError
+		backendRequestsTotal.With(remoteLabels).Inc()
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, requestURL)
+	}
+
+	remoteLabels["result"] = resultSuccess
+	backendRequestsTotal.With(remoteLabels).Inc()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+// currentStateVersionID returns the ID of the current state version of the workspace
+func (c *remoteClient) currentStateVersionID(
+	ctx context.Context,
+	remoteSpec outputsv1alpha1.RemoteSpec,
+) (string, error) {
+	var workspace remoteWorkspace
+	err := c.get(ctx, "ReadWorkspace", fmt.Sprintf(
+		"%s/organizations/%s/workspaces/%s",
+		c.baseURL,
+		url.PathEscape(remoteSpec.Organization),
+		url.PathEscape(remoteSpec.Workspace),
+	), &workspace)
+	if err != nil {
+		return "", fmt.Errorf("failed to read workspace: %w", err)
+	}
+
+	stateVersion := workspace.Data.Relationships.CurrentStateVersion.Data
+	if stateVersion == nil || stateVersion.ID == "" {
+		return "", fmt.Errorf("workspace %s/%s has no state version", remoteSpec.Organization, remoteSpec.Workspace)
+	}
+
+	return stateVersion.ID, nil
+}
+
+// getRemoteStateVersion gets the current state version ID of a Terraform Cloud workspace
+func (r *TerraformOutputsReconciler) getRemoteStateVersion(
+	ctx context.Context,
+	remoteSpec outputsv1alpha1.RemoteSpec,
+	namespace, name string,
+) (string, error) {
+	client, err := r.newRemoteClient(ctx, remoteSpec, namespace, name)
+	if err != nil {
+		return "", err
+	}
+
+	return client.currentStateVersionID(ctx, remoteSpec)
+}
+
+// fetchTerraformOutputsFromRemote fetches outputs from a Terraform Cloud workspace
+// through the state version outputs API
+func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromRemote(
+	ctx context.Context,
+	remoteSpec outputsv1alpha1.RemoteSpec,
+	backendName string,
+	namespace, name string,
+) (map[string]interface{}, map[string]bool, error) {
+	logger := log.FromContext(ctx)
+
+	client, err := r.newRemoteClient(ctx, remoteSpec, namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stateVersionID, err := client.currentStateVersionID(ctx, remoteSpec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Info(
+		"Reading Terraform state version outputs",
+		"backend",
+		backendName,
+		"organization",
+		remoteSpec.Organization,
+		"workspace",
+		remoteSpec.Workspace,
+		"stateVersion",
+		stateVersionID,
+	)
+
+	outputs := make(map[string]interface{})
+	sensitiveFlags := make(map[string]bool)
+
+	nextURL := fmt.Sprintf("%s/state-versions/%s/outputs?page%%5Bsize%%5D=100", client.baseURL, stateVersionID)
+	for nextURL != "" {
+		var page remoteStateVersionOutputs
+		if err := client.get(ctx, "ListStateVersionOutputs", nextURL, &page); err != nil {
+			return nil, nil, fmt.Errorf("failed to list state version outputs: %w", err)
+		}
+
+		for _, output := range page.Data {
+			value := output.Attributes.Value
+
+			// Sensitive values are redacted in the list response and have to be read one by one
+			if output.Attributes.Sensitive && value == nil {
+				var sensitiveOutput struct {
+					Data remoteStateVersionOutput `json:"data"`
+				}
+				if err := client.get(
+					ctx,
+					"ReadStateVersionOutput",
+					fmt.Sprintf("%s/state-version-outputs/%s", client.baseURL, output.ID),
+					&sensitiveOutput,
+				); err != nil {
+					return nil, nil, fmt.Errorf(
+						"failed to read sensitive output %s: %w",
+						output.Attributes.Name,
+						err,
+					)
+				}
+				value = sensitiveOutput.Data.Attributes.Value
+			}
+
+			outputs[output.Attributes.Name] = value
+			sensitiveFlags[output.Attributes.Name] = output.Attributes.Sensitive
+		}
+
+		if nextURL, err = client.resolveLink(page.Links.Next); err != nil {
+			return nil, nil, fmt.Errorf("invalid next page link: %w", err)
+		}
+	}
+
+	return outputs, sensitiveFlags, nil
+}
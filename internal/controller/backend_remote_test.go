@@ -0,0 +1,122 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+var _ = Describe("Remote backend", func() {
+	It("should resolve the API base URL from the hostname", func() {
+		Expect(remoteAPIBaseURL(outputsv1alpha1.RemoteSpec{})).
+			To(Equal("https://app.terraform.io/api/v2"))
+		Expect(remoteAPIBaseURL(outputsv1alpha1.RemoteSpec{Hostname: "tfe.example.com"})).
+			To(Equal("https://tfe.example.com/api/v2"))
+		Expect(remoteAPIBaseURL(outputsv1alpha1.RemoteSpec{Hostname: "http://tfe.local:8080/"})).
+			To(Equal("http://tfe.local:8080/api/v2"))
+	})
+
+	It("should only follow pagination links to the API host", func() {
+		client := &remoteClient{baseURL: "https://app.terraform.io/api/v2"}
+
+		Expect(client.resolveLink("")).To(BeEmpty())
+		Expect(client.resolveLink("/api/v2/state-versions/sv-1/outputs?page%5Bnumber%5D=2")).
+			To(Equal("https://app.terraform.io/api/v2/state-versions/sv-1/outputs?page%5Bnumber%5D=2"))
+		Expect(client.resolveLink("https://app.terraform.io/api/v2/state-versions/sv-1/outputs")).
+			To(Equal("https://app.terraform.io/api/v2/state-versions/sv-1/outputs"))
+
+		_, err := client.resolveLink("https://attacker.example.com/api/v2/state-versions/sv-1/outputs")
+		Expect(err).To(MatchError(ContainSubstring("does not point to app.terraform.io")))
+		_, err = client.resolveLink("http://app.terraform.io/api/v2/state-versions/sv-1/outputs")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("When reconciling a resource with a remote backend", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+
+		BeforeEach(func() {
+			// Mock the Terraform Cloud workspace and state version output APIs
+			mockTFCServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("Authorization") != "Bearer test-token" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					w.Header().Set("Content-Type", remoteAPIMediaType)
+
+					var body string
+					switch r.URL.Path {
+					case "/api/v2/organizations/acme/workspaces/network-prod":
+						body = `{"data":{"id":"ws-123","relationships":` +
+							`{"current-state-version":{"data":{"id":"sv-456","type":"state-versions"}}}}}`
+					case "/api/v2/state-versions/sv-456/outputs":
+						body = `{"data":[` +
+							`{"id":"wsout-1","attributes":{"name":"vpc_id","sensitive":false,"value":"vpc-abc"}},` +
+							`{"id":"wsout-2","attributes":{"name":"db_password","sensitive":true,"value":null}}` +
+							`],"links":{"next":null}}`
+					case "/api/v2/state-version-outputs/wsout-2":
+						body = `{"data":{"id":"wsout-2","attributes":` +
+							`{"name":"db_password","sensitive":true,"value":"hunter2"}}}`
+					default:
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					_, err := w.Write([]byte(body))
+					Expect(err).NotTo(HaveOccurred())
+				}),
+			)
+			DeferCleanup(mockTFCServer.Close)
+
+			resource = newTestTerraformOutputs("test-remote-resource", outputsv1alpha1.BackendSpec{
+				Name: "remote",
+				Remote: &outputsv1alpha1.RemoteSpec{
+					Hostname:     mockTFCServer.URL,
+					Organization: "acme",
+					Workspace:    "network-prod",
+					TokenSecretRef: outputsv1alpha1.SecretKeyReference{
+						Name: "tfc-token",
+						Key:  "token",
+					},
+				},
+			})
+			createTestObjects(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "tfc-token",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{"token": []byte("test-token")},
+			}, resource)
+		})
+
+		It("should sync outputs including sensitive values", func() {
+			Expect(reconcileTestTerraformOutputs(ctx, newTestReconciler(), resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("vpc_id", "vpc-abc"))
+			Expect(string(syncedSecret(ctx, resource).Data["db_password"])).To(Equal("hunter2"))
+			Expect(resource.Annotations).To(HaveKeyWithValue(StateVersionAnnotationPrefix+"remote", "sv-456"))
+		})
+	})
+})
@@ -0,0 +1,483 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// maxRoleSessionNameLength is the maximum length of an STS role session name
+const maxRoleSessionNameLength = 64
+
+// readAWSCredentials reads static AWS credentials from the referenced Secret
+func (r *TerraformOutputsReconciler) readAWSCredentials(
+	ctx context.Context,
+	ref outputsv1alpha1.AWSCredentialsSecretReference,
+	namespace string,
+) (aws.Credentials, error) {
+	accessKeyIDKey := ref.AccessKeyIDKey
+	if accessKeyIDKey == "" {
+		accessKeyIDKey = "AWS_ACCESS_KEY_ID"
+	}
+	secretAccessKeyKey := ref.SecretAccessKeyKey
+	if secretAccessKeyKey == "" {
+		secretAccessKeyKey = "AWS_SECRET_ACCESS_KEY"
+	}
+	sessionTokenKey := ref.SessionTokenKey
+	if sessionTokenKey == "" {
+		sessionTokenKey = "AWS_SESSION_TOKEN"
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret); err != nil {
+		return aws.Credentials{}, fmt.Errorf("failed to get Secret %s/%s: %w", namespace, ref.Name, err)
+	}
+
+	accessKeyID, ok := secret.Data[accessKeyIDKey]
+	if !ok {
+		return aws.Credentials{}, fmt.Errorf("key %q not found in Secret %s/%s", accessKeyIDKey, namespace, ref.Name)
+	}
+	secretAccessKey, ok := secret.Data[secretAccessKeyKey]
+	if !ok {
+		return aws.Credentials{}, fmt.Errorf(
+			"key %q not found in Secret %s/%s",
+			secretAccessKeyKey,
+			namespace,
+			ref.Name,
+		)
+	}
+
+	return aws.Credentials{
+		AccessKeyID:     strings.TrimSpace(string(accessKeyID)),
+		SecretAccessKey: strings.TrimSpace(string(secretAccessKey)),
+		SessionToken:    strings.TrimSpace(string(secret.Data[sessionTokenKey])),
+		Source:          "TerraformOutputsCredentialsSecret",
+	}, nil
+}
+
+// roleSessionName returns the session name used when assuming the role of an S3 backend
+func roleSessionName(s3Spec outputsv1alpha1.S3Spec, namespace, name string) string {
+	if s3Spec.RoleSessionName != "" {
+		return s3Spec.RoleSessionName
+	}
+
+	sessionName := fmt.Sprintf("tfout-%s-%s", namespace, name)
+	if len(sessionName) > maxRoleSessionNameLength {
+		sessionName = sessionName[:maxRoleSessionNameLength]
+	}
+	return sessionName
+}
+
+// newS3Client returns a cached S3 client for a backend, using the credentials from its
+// Secret instead of the controller's own credentials when referenced, and assuming its
+// role when one is configured. Cached clients refresh expiring credentials themselves.
+func (r *TerraformOutputsReconciler) newS3Client(
+	ctx context.Context,
+	s3Spec outputsv1alpha1.S3Spec,
+	namespace, name string,
+) (*s3.Client, error) {
+	var roleDuration time.Duration
+	if s3Spec.RoleDuration != "" {
+		var err error
+		if roleDuration, err = time.ParseDuration(s3Spec.RoleDuration); err != nil {
+			return nil, fmt.Errorf("invalid role duration: %w", err)
+		}
+	}
+
+	loadOptions := []func(*config.LoadOptions) error{config.WithRegion(s3Spec.Region)}
+
+	// The source credentials are part of the cache key, so clients using different
+	// tenants' credentials, or rotated credentials, are never shared
+	sourceCredentials := "default"
+	if s3Spec.CredentialsSecretRef != nil {
+		staticCredentials, err := r.readAWSCredentials(ctx, *s3Spec.CredentialsSecretRef, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AWS credentials: %w", err)
+		}
+		loadOptions = append(loadOptions, config.WithCredentialsProvider(
+			credentials.StaticCredentialsProvider{Value: staticCredentials},
+		))
+		credentialsHash := sha256.Sum256([]byte(strings.Join([]string{
+			staticCredentials.AccessKeyID,
+			staticCredentials.SecretAccessKey,
+			staticCredentials.SessionToken,
+		}, "\x00")))
+		sourceCredentials = fmt.Sprintf(
+			"%s/%s/%s",
+			namespace,
+			s3Spec.CredentialsSecretRef.Name,
+			hex.EncodeToString(credentialsHash[:]),
+		)
+	}
+
+	// The role options are only part of the cache key when a role is assumed, so backends
+	// sharing credentials, region and endpoint share a client across resources
+	sessionName := roleSessionName(s3Spec, namespace, name)
+	cacheKeyParts := []string{sourceCredentials, s3Spec.Region, s3Spec.Endpoint}
+	if s3Spec.Role != "" {
+		cacheKeyParts = append(cacheKeyParts,
+			s3Spec.Role,
+			sessionName,
+			s3Spec.RoleExternalID,
+			roleDuration.String(),
+			s3Spec.STSEndpoint,
+		)
+	}
+	cacheKey := strings.Join(cacheKeyParts, "|")
+
+	return r.s3Clients.get("s3", cacheKey, func() (*s3.Client, error) {
+		// Load AWS configuration
+		cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+
+		if s3Spec.Role != "" {
+			stsClient := sts.NewFromConfig(cfg, func(o *sts.Options) {
+				if s3Spec.STSEndpoint != "" {
+					o.BaseEndpoint = aws.String(s3Spec.STSEndpoint)
+				}
+			})
+
+			// The credentials cache only calls STS again once the assumed role session is about to expire
+			cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(
+				stsClient,
+				s3Spec.Role,
+				func(o *stscreds.AssumeRoleOptions) {
+					o.RoleSessionName = sessionName
+					if s3Spec.RoleExternalID != "" {
+						o.ExternalID = aws.String(s3Spec.RoleExternalID)
+					}
+					if roleDuration > 0 {
+						o.Duration = roleDuration
+					}
+				},
+			))
+		}
+
+		// Create S3 client with optional custom endpoint
+		if s3Spec.Endpoint != "" {
+			return s3.NewFromConfig(cfg, func(o *s3.Options) {
+				o.BaseEndpoint = aws.String(s3Spec.Endpoint)
+				o.UsePathStyle = true // Often needed for S3-compatible services
+			}), nil
+		}
+		return s3.NewFromConfig(cfg), nil
+	})
+}
+
+// defaultS3WorkspaceKeyPrefix is the Terraform s3 backend's default workspace_key_prefix
+const defaultS3WorkspaceKeyPrefix = "env:"
+
+// s3WorkspaceKeyPrefix returns the prefix of non-default workspace state keys
+func s3WorkspaceKeyPrefix(s3Spec outputsv1alpha1.S3Spec) string {
+	if s3Spec.WorkspaceKeyPrefix == "" {
+		return defaultS3WorkspaceKeyPrefix
+	}
+	return strings.Trim(s3Spec.WorkspaceKeyPrefix, "/")
+}
+
+// s3StateKey resolves the state key of the configured workspace the same way as the
+// Terraform s3 backend: <workspaceKeyPrefix>/<workspace>/<key> for non-default workspaces
+func s3StateKey(s3Spec outputsv1alpha1.S3Spec) (string, error) {
+	if s3Spec.AllWorkspaces && s3Spec.Workspace != "" {
+		return "", fmt.Errorf("workspace and allWorkspaces are mutually exclusive")
+	}
+	if (s3Spec.Key == "") == (s3Spec.KeyPattern == "") {
+		return "", fmt.Errorf("exactly one of key or keyPattern must be specified")
+	}
+	if s3Spec.KeyPattern != "" && (s3Spec.Workspace != "" || s3Spec.AllWorkspaces) {
+		return "", fmt.Errorf("keyPattern cannot be combined with workspace or allWorkspaces")
+	}
+
+	if s3Spec.Workspace == "" || s3Spec.Workspace == "default" {
+		return s3Spec.Key, nil
+	}
+	return fmt.Sprintf("%s/%s/%s", s3WorkspaceKeyPrefix(s3Spec), s3Spec.Workspace, s3Spec.Key), nil
+}
+
+// s3StateObject is a state file found by listing a bucket
+type s3StateObject struct {
+	Key  string
+	ETag string
+}
+
+// listS3Objects lists all objects under a prefix
+func (r *TerraformOutputsReconciler) listS3Objects(
+	ctx context.Context,
+	s3Client *s3.Client,
+	bucket, prefix string,
+	namespace, name string,
+) ([]s3types.Object, error) {
+	s3Labels := prometheus.Labels{
+		"namespace": namespace,
+		"name":      name,
+		"operation": "ListObjectsV2",
+	}
+
+	var objects []s3types.Object
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			s3Labels["result"] = resultError
+			s3RequestsTotal.With(s3Labels).Inc()
+			return nil, fmt.Errorf("failed to list S3 objects under %q: %w", prefix, err)
+		}
+		s3Labels["result"] = resultSuccess
+		s3RequestsTotal.With(s3Labels).Inc()
+
+		objects = append(objects, page.Contents...)
+	}
+
+	return objects, nil
+}
+
+// listS3WorkspaceStates finds the state of every workspace, keyed by workspace name
+func (r *TerraformOutputsReconciler) listS3WorkspaceStates(
+	ctx context.Context,
+	s3Client *s3.Client,
+	s3Spec outputsv1alpha1.S3Spec,
+	namespace, name string,
+) (map[string]s3StateObject, error) {
+	if _, err := s3StateKey(s3Spec); err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]s3StateObject)
+
+	// The default workspace is stored at the key itself
+	defaultObjects, err := r.listS3Objects(ctx, s3Client, s3Spec.Bucket, s3Spec.Key, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range defaultObjects {
+		if aws.ToString(object.Key) == s3Spec.Key {
+			states["default"] = s3StateObject{
+				Key:  s3Spec.Key,
+				ETag: strings.Trim(aws.ToString(object.ETag), "\""),
+			}
+		}
+	}
+
+	workspacePrefix := s3WorkspaceKeyPrefix(s3Spec) + "/"
+	workspaceObjects, err := r.listS3Objects(ctx, s3Client, s3Spec.Bucket, workspacePrefix, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range workspaceObjects {
+		workspace, key, ok := strings.Cut(strings.TrimPrefix(aws.ToString(object.Key), workspacePrefix), "/")
+		if !ok || workspace == "" || key != s3Spec.Key {
+			continue
+		}
+		states[workspace] = s3StateObject{
+			Key:  aws.ToString(object.Key),
+			ETag: strings.Trim(aws.ToString(object.ETag), "\""),
+		}
+	}
+
+	return states, nil
+}
+
+// listS3States lists the state files of a backend reading many states, keyed by the label
+// their output keys are prefixed with
+func (r *TerraformOutputsReconciler) listS3States(
+	ctx context.Context,
+	s3Client *s3.Client,
+	s3Spec outputsv1alpha1.S3Spec,
+	namespace, name string,
+) (map[string]s3StateObject, error) {
+	if s3Spec.KeyPattern != "" {
+		return r.listS3PatternStates(ctx, s3Client, s3Spec, namespace, name)
+	}
+	return r.listS3WorkspaceStates(ctx, s3Client, s3Spec, namespace, name)
+}
+
+// s3KeyPatternLabel matches a key against a key pattern, returning the label identifying
+// the state file: the path below the prefix, or the path segments matched by wildcards
+func s3KeyPatternLabel(pattern, key string) (string, bool) {
+	if prefix, ok := strings.CutSuffix(pattern, "/"); ok {
+		relative, found := strings.CutPrefix(key, prefix+"/")
+		if !found || !strings.HasSuffix(relative, ".tfstate") {
+			return "", false
+		}
+		return sanitizeOutputKeyPrefix(strings.TrimSuffix(relative, ".tfstate")), true
+	}
+
+	if matched, err := path.Match(pattern, key); err != nil || !matched {
+		return "", false
+	}
+
+	// path.Match wildcards never match /, so the segments line up
+	patternSegments := strings.Split(pattern, "/")
+	keySegments := strings.Split(key, "/")
+	var labelSegments []string
+	for i, segment := range patternSegments {
+		if strings.ContainsAny(segment, "*?[\\") {
+			labelSegments = append(labelSegments, strings.TrimSuffix(keySegments[i], ".tfstate"))
+		}
+	}
+	if len(labelSegments) == 0 {
+		return "", false
+	}
+	return sanitizeOutputKeyPrefix(strings.Join(labelSegments, "/")), true
+}
+
+// sanitizeOutputKeyPrefix turns a path into a valid ConfigMap key prefix
+func sanitizeOutputKeyPrefix(label string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, label)
+}
+
+// validateS3KeyPattern checks that a key pattern is a prefix ending in / or a valid glob with
+// at least one wildcard, since a literal key would match a state file without a label
+func validateS3KeyPattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid key pattern %q: %w", pattern, err)
+	}
+	if !strings.HasSuffix(pattern, "/") && !strings.ContainsAny(pattern, "*?[") {
+		return fmt.Errorf("key pattern %q must end in / or contain a wildcard, use key to read a single state file", pattern)
+	}
+	return nil
+}
+
+// listS3PatternStates finds the state files matching the key pattern
+func (r *TerraformOutputsReconciler) listS3PatternStates(
+	ctx context.Context,
+	s3Client *s3.Client,
+	s3Spec outputsv1alpha1.S3Spec,
+	namespace, name string,
+) (map[string]s3StateObject, error) {
+	if _, err := s3StateKey(s3Spec); err != nil {
+		return nil, err
+	}
+	if err := validateS3KeyPattern(s3Spec.KeyPattern); err != nil {
+		return nil, err
+	}
+
+	// Only list below the literal part of the pattern
+	listPrefix := s3Spec.KeyPattern
+	if i := strings.IndexAny(listPrefix, "*?[\\"); i >= 0 {
+		listPrefix = listPrefix[:i]
+	}
+
+	objects, err := r.listS3Objects(ctx, s3Client, s3Spec.Bucket, listPrefix, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]s3StateObject)
+	for _, object := range objects {
+		label, ok := s3KeyPatternLabel(s3Spec.KeyPattern, aws.ToString(object.Key))
+		if !ok {
+			continue
+		}
+		if existing, found := states[label]; found {
+			return nil, fmt.Errorf(
+				"state files %s and %s map to the same output key prefix %q",
+				existing.Key,
+				aws.ToString(object.Key),
+				label,
+			)
+		}
+		states[label] = s3StateObject{
+			Key:  aws.ToString(object.Key),
+			ETag: strings.Trim(aws.ToString(object.ETag), "\""),
+		}
+	}
+
+	return states, nil
+}
+
+// s3StatesVersion combines the ETags of listed state files into a single version, which
+// also changes when state files are added or removed
+func s3StatesVersion(states map[string]s3StateObject) string {
+	labels := make([]string, 0, len(states))
+	for label := range states {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	hash := sha256.New()
+	for _, label := range labels {
+		_, _ = fmt.Fprintf(hash, "%s=%s\n", label, states[label].ETag)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// fetchTerraformOutputsFromS3States fetches the outputs of several listed state files,
+// prefixing each output key with the label of its state file
+func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromS3States(
+	ctx context.Context,
+	s3Client *s3.Client,
+	s3Spec outputsv1alpha1.S3Spec,
+	states map[string]s3StateObject,
+	backendName string,
+	namespace, name string,
+) (map[string]interface{}, map[string]bool, error) {
+	logger := log.FromContext(ctx)
+
+	if len(states) == 0 {
+		return nil, nil, fmt.Errorf("no state files found in bucket %s", s3Spec.Bucket)
+	}
+
+	outputs := make(map[string]interface{})
+	sensitiveFlags := make(map[string]bool)
+
+	for label, state := range states {
+		logger.Info(
+			"Downloading Terraform state",
+			"backend",
+			backendName,
+			"bucket",
+			s3Spec.Bucket,
+			"key",
+			state.Key,
+		)
+
+		body, err := r.downloadS3State(ctx, s3Client, s3Spec.Bucket, state.Key, namespace, name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		stateOutputs, stateSensitiveFlags, err := parseTerraformOutputs(withStateKey(ctx, state.Key), body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse state file %s: %w", state.Key, err)
+		}
+
+		for key, value := range stateOutputs {
+			outputs[label+"_"+key] = value
+			sensitiveFlags[label+"_"+key] = stateSensitiveFlags[key]
+		}
+	}
+
+	return outputs, sensitiveFlags, nil
+}
@@ -0,0 +1,518 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// mockS3Bucket is a path-style S3 bucket supporting HeadObject, GetObject and ListObjectsV2
+type mockS3Bucket struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]string
+}
+
+func (m *mockS3Bucket) put(key, body string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = body
+}
+
+func (m *mockS3Bucket) etag(body string) string {
+	sum := md5.Sum([]byte(body))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (m *mockS3Bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+m.bucket)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+
+	if key == "" && r.URL.Query().Get("list-type") == "2" {
+		prefix := r.URL.Query().Get("prefix")
+		keys := make([]string, 0, len(m.objects))
+		for objectKey := range m.objects {
+			if strings.HasPrefix(objectKey, prefix) {
+				keys = append(keys, objectKey)
+			}
+		}
+		sort.Strings(keys)
+
+		var contents strings.Builder
+		for _, objectKey := range keys {
+			_, _ = fmt.Fprintf(&contents, "<Contents><Key>%s</Key><ETag>%s</ETag><Size>%d</Size></Contents>",
+				objectKey, html.EscapeString(m.etag(m.objects[objectKey])), len(m.objects[objectKey]))
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, err := fmt.Fprintf(w, `<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount>`+
+			`<IsTruncated>false</IsTruncated>%s</ListBucketResult>`, m.bucket, prefix, len(keys), contents.String())
+		Expect(err).NotTo(HaveOccurred())
+		return
+	}
+
+	body, ok := m.objects[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("ETag", m.etag(body))
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, err := w.Write([]byte(body))
+	Expect(err).NotTo(HaveOccurred())
+}
+
+var _ = Describe("S3 backend", func() {
+	It("should resolve workspace state keys like the Terraform s3 backend", func() {
+		key, err := s3StateKey(outputsv1alpha1.S3Spec{Key: "network.tfstate"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal("network.tfstate"))
+
+		key, err = s3StateKey(outputsv1alpha1.S3Spec{Key: "network.tfstate", Workspace: "staging"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal("env:/staging/network.tfstate"))
+
+		key, err = s3StateKey(outputsv1alpha1.S3Spec{
+			Key:                "network.tfstate",
+			Workspace:          "staging",
+			WorkspaceKeyPrefix: "workspaces",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal("workspaces/staging/network.tfstate"))
+
+		_, err = s3StateKey(outputsv1alpha1.S3Spec{Key: "network.tfstate", Workspace: "staging", AllWorkspaces: true})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should default the role session name to the resource", func() {
+		Expect(roleSessionName(outputsv1alpha1.S3Spec{}, "default", "network")).
+			To(Equal("tfout-default-network"))
+		Expect(roleSessionName(outputsv1alpha1.S3Spec{RoleSessionName: "custom"}, "default", "network")).
+			To(Equal("custom"))
+		Expect(roleSessionName(outputsv1alpha1.S3Spec{}, "default", strings.Repeat("x", 100))).
+			To(HaveLen(maxRoleSessionNameLength))
+	})
+
+	It("should label state files matching a key pattern", func() {
+		for _, tc := range []struct {
+			pattern, key, label string
+			matched             bool
+		}{
+			{"services/*/terraform.tfstate", "services/billing/terraform.tfstate", "billing", true},
+			{"services/*/terraform.tfstate", "services/billing/nested/terraform.tfstate", "", false},
+			{"services/*/terraform.tfstate", "services/billing/other.tfstate", "", false},
+			{"*/services/*.tfstate", "eu/services/billing.tfstate", "eu_billing", true},
+			{"services/", "services/billing/terraform.tfstate", "billing_terraform", true},
+			{"services/", "services/billing.tfstate", "billing", true},
+			{"services/", "services/billing.tfstate.backup", "", false},
+			{"services/", "servicesx/billing.tfstate", "", false},
+			{"services/network.tfstate", "services/network.tfstate", "", false},
+		} {
+			label, matched := s3KeyPatternLabel(tc.pattern, tc.key)
+			Expect(matched).To(Equal(tc.matched), "%s ~ %s", tc.pattern, tc.key)
+			Expect(label).To(Equal(tc.label), "%s ~ %s", tc.pattern, tc.key)
+		}
+	})
+
+	It("should reject key patterns without a wildcard", func() {
+		Expect(validateS3KeyPattern("services/")).To(Succeed())
+		Expect(validateS3KeyPattern("services/*/terraform.tfstate")).To(Succeed())
+		Expect(validateS3KeyPattern("services/network.tfstate")).
+			To(MatchError(ContainSubstring("must end in / or contain a wildcard")))
+		Expect(validateS3KeyPattern("services/[")).To(MatchError(ContainSubstring("invalid key pattern")))
+	})
+
+	It("should reuse cached clients and evict idle ones", func() {
+		now := time.Now()
+		cache := &clientCache[*s3.Client]{now: func() time.Time { return now }}
+
+		creates := 0
+		create := func() (*s3.Client, error) {
+			creates++
+			return s3.New(s3.Options{Region: "us-east-1"}), nil
+		}
+
+		hitsBefore := testutil.ToFloat64(clientCacheRequestsTotal.WithLabelValues("s3", "hit"))
+		missesBefore := testutil.ToFloat64(clientCacheRequestsTotal.WithLabelValues("s3", "miss"))
+
+		first, err := cache.get("s3", "us-east-1|a", create)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := cache.get("s3", "us-east-1|a", create)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second).To(BeIdenticalTo(first))
+		_, err = cache.get("s3", "us-east-1|b", create)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creates).To(Equal(2))
+
+		By("Evicting clients after the idle timeout")
+		now = now.Add(clientIdleTimeout + time.Minute)
+		third, err := cache.get("s3", "us-east-1|a", create)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(third).NotTo(BeIdenticalTo(first))
+		Expect(creates).To(Equal(3))
+		Expect(cache.entries).To(HaveLen(1))
+
+		Expect(testutil.ToFloat64(clientCacheRequestsTotal.WithLabelValues("s3", "hit")) - hitsBefore).
+			To(BeNumerically("==", 1))
+		Expect(testutil.ToFloat64(clientCacheRequestsTotal.WithLabelValues("s3", "miss")) - missesBefore).
+			To(BeNumerically("==", 3))
+	})
+
+	It("should create a client once for concurrent requests", func() {
+		cache := &clientCache[*s3.Client]{}
+		release := make(chan struct{})
+		var creates atomic.Int32
+		create := func() (*s3.Client, error) {
+			creates.Add(1)
+			<-release
+			return s3.New(s3.Options{Region: "us-east-1"}), nil
+		}
+
+		clients := make(chan *s3.Client, 2)
+		for range 2 {
+			go func() {
+				defer GinkgoRecover()
+				client, err := cache.get("s3", "us-east-1|a", create)
+				Expect(err).NotTo(HaveOccurred())
+				clients <- client
+			}()
+		}
+
+		By("Serving other keys while a client is being created")
+		Eventually(creates.Load).Should(Equal(int32(1)))
+		_, err := cache.get("s3", "us-east-1|b", func() (*s3.Client, error) {
+			return s3.New(s3.Options{Region: "us-east-1"}), nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		close(release)
+		first, second := <-clients, <-clients
+		Expect(second).To(BeIdenticalTo(first))
+		Expect(creates.Load()).To(Equal(int32(1)))
+	})
+
+	It("should share clients between resources without a role", func() {
+		GinkgoT().Setenv("AWS_ACCESS_KEY_ID", "test")
+		GinkgoT().Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+		ctx := context.Background()
+		controllerReconciler := newTestReconciler()
+		s3Spec := outputsv1alpha1.S3Spec{Region: "us-east-1", Endpoint: "http://s3.example.com"}
+
+		first, err := controllerReconciler.newS3Client(ctx, s3Spec, "default", "network")
+		Expect(err).NotTo(HaveOccurred())
+		second, err := controllerReconciler.newS3Client(ctx, s3Spec, "apps", "database")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second).To(BeIdenticalTo(first))
+
+		By("Using a client per resource when assuming a role")
+		s3Spec.Role = "arn:aws:iam::123456789012:role/terraform-reader"
+		first, err = controllerReconciler.newS3Client(ctx, s3Spec, "default", "network")
+		Expect(err).NotTo(HaveOccurred())
+		second, err = controllerReconciler.newS3Client(ctx, s3Spec, "apps", "database")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second).NotTo(BeIdenticalTo(first))
+	})
+
+	Context("When reconciling a resource with an S3 role", func() {
+		const resourceName = "test-s3-role-resource"
+
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+		var assumeRoleCalls atomic.Int32
+
+		BeforeEach(func() {
+			assumeRoleCalls.Store(0)
+
+			// Mock the STS AssumeRole query API
+			mockSTSServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Expect(r.ParseForm()).To(Succeed())
+					if r.Form.Get("Action") != "AssumeRole" ||
+						r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/terraform-reader" ||
+						r.Form.Get("RoleSessionName") != "tfout-default-"+resourceName ||
+						r.Form.Get("ExternalId") != "tfout-external-id" ||
+						r.Form.Get("DurationSeconds") != "3600" {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					assumeRoleCalls.Add(1)
+
+					w.Header().Set("Content-Type", "text/xml")
+					_, err := fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIAASSUMED</AccessKeyId>
+      <SecretAccessKey>assumed-secret</SecretAccessKey>
+      <SessionToken>assumed-token</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <AssumedRoleId>AROA123:tfout</AssumedRoleId>
+      <Arn>arn:aws:sts::123456789012:assumed-role/terraform-reader/tfout</Arn>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+  <ResponseMetadata><RequestId>test</RequestId></ResponseMetadata>
+</AssumeRoleResponse>`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+					Expect(err).NotTo(HaveOccurred())
+				}),
+			)
+			DeferCleanup(mockSTSServer.Close)
+
+			// Mock S3, only accepting requests signed with the assumed role credentials
+			mockS3Server := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !strings.Contains(r.Header.Get("Authorization"), "Credential=ASIAASSUMED/") ||
+						r.Header.Get("X-Amz-Security-Token") != "assumed-token" {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					w.Header().Set("ETag", "\"assumed-etag\"")
+					if r.Method == http.MethodHead {
+						return
+					}
+					_, err := w.Write([]byte(`{"outputs":{"vpc_id":{"value":"vpc-cross-account","sensitive":false}}}`))
+					Expect(err).NotTo(HaveOccurred())
+				}),
+			)
+			DeferCleanup(mockS3Server.Close)
+
+			GinkgoT().Setenv("AWS_ACCESS_KEY_ID", "test")
+			GinkgoT().Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+			resource = newTestTerraformOutputs(resourceName, outputsv1alpha1.BackendSpec{
+				Name: "s3",
+				S3: &outputsv1alpha1.S3Spec{
+					Bucket:         "cross-account-bucket",
+					Key:            "network.tfstate",
+					Region:         "us-east-1",
+					Endpoint:       mockS3Server.URL,
+					Role:           "arn:aws:iam::123456789012:role/terraform-reader",
+					RoleExternalID: "tfout-external-id",
+					RoleDuration:   "1h",
+					STSEndpoint:    mockSTSServer.URL,
+				},
+			})
+			createTestObjects(ctx, resource)
+		})
+
+		It("should read the state with cached assumed role credentials", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("vpc_id", "vpc-cross-account"))
+
+			By("Reusing the assumed role credentials until they expire")
+			_, _, err := controllerReconciler.checkBackendChanges(ctx, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(assumeRoleCalls.Load()).To(Equal(int32(1)))
+		})
+	})
+
+	Context("When reconciling a resource with S3 credentials from a Secret", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+
+		BeforeEach(func() {
+			// Mock S3, only accepting requests signed with the tenant credentials
+			mockS3Server := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKIATENANT/") ||
+						r.Header.Get("X-Amz-Security-Token") != "" {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					w.Header().Set("ETag", "\"tenant-etag\"")
+					if r.Method == http.MethodHead {
+						return
+					}
+					_, err := w.Write([]byte(`{"outputs":{"vpc_id":{"value":"vpc-tenant","sensitive":false}}}`))
+					Expect(err).NotTo(HaveOccurred())
+				}),
+			)
+			DeferCleanup(mockS3Server.Close)
+
+			// The controller's own credentials must not be used
+			GinkgoT().Setenv("AWS_ACCESS_KEY_ID", "AKIACONTROLLER")
+			GinkgoT().Setenv("AWS_SECRET_ACCESS_KEY", "controller-secret")
+
+			resource = newTestTerraformOutputs("test-s3-credentials-resource", outputsv1alpha1.BackendSpec{
+				Name: "s3",
+				S3: &outputsv1alpha1.S3Spec{
+					Bucket:   "tenant-bucket",
+					Key:      "network.tfstate",
+					Region:   "us-east-1",
+					Endpoint: mockS3Server.URL,
+					CredentialsSecretRef: &outputsv1alpha1.AWSCredentialsSecretReference{
+						Name: "tenant-aws-credentials",
+					},
+				},
+			})
+			createTestObjects(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "tenant-aws-credentials",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{
+					"AWS_ACCESS_KEY_ID":     []byte("AKIATENANT"),
+					"AWS_SECRET_ACCESS_KEY": []byte("tenant-secret"),
+				},
+			}, resource)
+		})
+
+		It("should read the state with the credentials from the Secret only", func() {
+			Expect(reconcileTestTerraformOutputs(ctx, newTestReconciler(), resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("vpc_id", "vpc-tenant"))
+		})
+	})
+
+	Context("When reconciling a resource reading all S3 workspaces", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+		var bucket *mockS3Bucket
+
+		BeforeEach(func() {
+			bucket = &mockS3Bucket{bucket: "workspace-bucket", objects: map[string]string{
+				"network.tfstate":                `{"outputs":{"vpc_id":{"value":"vpc-default","sensitive":false}}}`,
+				"env:/staging/network.tfstate":   `{"outputs":{"vpc_id":{"value":"vpc-staging","sensitive":false}}}`,
+				"env:/staging/other.tfstate":     `{"outputs":{"vpc_id":{"value":"vpc-other","sensitive":false}}}`,
+				"env:/prod/network.tfstate":      `{"outputs":{"db_password":{"value":"prod-secret","sensitive":true}}}`,
+				"env:/prod/nested/a.tfstate":     `{"outputs":{}}`,
+				"network.tfstate.backup":         `{"outputs":{}}`,
+				"unrelated/env:/network.tfstate": `{"outputs":{}}`,
+			}}
+			mockS3Server := httptest.NewServer(bucket)
+			DeferCleanup(mockS3Server.Close)
+
+			GinkgoT().Setenv("AWS_ACCESS_KEY_ID", "test")
+			GinkgoT().Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+			resource = newTestTerraformOutputs("test-s3-workspaces-resource", outputsv1alpha1.BackendSpec{
+				Name: "s3",
+				S3: &outputsv1alpha1.S3Spec{
+					Bucket:        "workspace-bucket",
+					Key:           "network.tfstate",
+					Region:        "us-east-1",
+					Endpoint:      mockS3Server.URL,
+					AllWorkspaces: true,
+				},
+			})
+			createTestObjects(ctx, resource)
+		})
+
+		It("should sync one prefixed output set per workspace", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(Equal(map[string]string{
+				"default_vpc_id": "vpc-default",
+				"staging_vpc_id": "vpc-staging",
+			}))
+			Expect(string(syncedSecret(ctx, resource).Data["prod_db_password"])).To(Equal("prod-secret"))
+
+			By("Detecting new workspaces on the next sync")
+			changed, _, err := controllerReconciler.checkBackendChanges(ctx, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeFalse())
+
+			bucket.put("env:/dev/network.tfstate", `{"outputs":{"vpc_id":{"value":"vpc-dev","sensitive":false}}}`)
+			changed, _, err = controllerReconciler.checkBackendChanges(ctx, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeTrue())
+		})
+	})
+
+	Context("When reconciling a resource discovering S3 state files by key pattern", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+		var bucket *mockS3Bucket
+
+		BeforeEach(func() {
+			bucket = &mockS3Bucket{bucket: "services-bucket", objects: map[string]string{
+				"services/billing/terraform.tfstate": `{"outputs":{"queue_url":{"value":"sqs-billing","sensitive":false}}}`,
+				"services/orders/terraform.tfstate":  `{"outputs":{"queue_url":{"value":"sqs-orders","sensitive":false}}}`,
+				"services/orders/terraform.tfvars":   `orders = true`,
+				"platform/terraform.tfstate":         `{"outputs":{"vpc_id":{"value":"vpc-1","sensitive":false}}}`,
+			}}
+			mockS3Server := httptest.NewServer(bucket)
+			DeferCleanup(mockS3Server.Close)
+
+			GinkgoT().Setenv("AWS_ACCESS_KEY_ID", "test")
+			GinkgoT().Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+			resource = newTestTerraformOutputs("test-s3-pattern-resource", outputsv1alpha1.BackendSpec{
+				Name: "s3",
+				S3: &outputsv1alpha1.S3Spec{
+					Bucket:     "services-bucket",
+					KeyPattern: "services/*/terraform.tfstate",
+					Region:     "us-east-1",
+					Endpoint:   mockS3Server.URL,
+				},
+			})
+			createTestObjects(ctx, resource)
+		})
+
+		It("should sync the outputs of every matching state file", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(Equal(map[string]string{
+				"billing_queue_url": "sqs-billing",
+				"orders_queue_url":  "sqs-orders",
+			}))
+
+			By("Picking up new state files on the next sync")
+			bucket.put(
+				"services/payments/terraform.tfstate",
+				`{"outputs":{"queue_url":{"value":"sqs-payments","sensitive":false}}}`,
+			)
+			resource.Status.LastSyncTime = nil
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("payments_queue_url", "sqs-payments"))
+		})
+	})
+})
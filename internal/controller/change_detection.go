@@ -0,0 +1,61 @@
+package controller
+
+import (
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const (
+	// changeDetectionVersion syncs whenever the object version reported by a backend changes
+	changeDetectionVersion = "Version"
+
+	// changeDetectionSerial only syncs when the lineage or serial of a state changes
+	changeDetectionSerial = "Serial"
+
+	// changeDetectionContentHash only syncs when the content of a state changes
+	changeDetectionContentHash = "ContentHash"
+)
+
+// stateChanged reports whether the states fetched from the backends differ from the ones
+// recorded in the last successful sync, according to the change detection mode of the spec.
+// Backends that record no state, such as remote, are always considered changed.
+func stateChanged(tfOutputs *outputsv1alpha1.TerraformOutputs, backendStatuses []outputsv1alpha1.BackendStatus) bool {
+	mode := tfOutputs.Spec.ChangeDetection
+	if mode == "" || mode == changeDetectionVersion {
+		return true
+	}
+
+	// A spec change may change what is synced, even when the states did not
+	if tfOutputs.Status.ObservedGeneration != tfOutputs.Generation ||
+		len(backendStatuses) != len(tfOutputs.Status.Backends) {
+		return true
+	}
+
+	recorded := make(map[string]bool, len(tfOutputs.Spec.Backends))
+	for i, state := range backendStatuses {
+		previous := tfOutputs.Status.Backends[i]
+		recorded[state.Name] = true
+
+		if state.Name != previous.Name || state.Type != previous.Type || state.State != previous.State {
+			return true
+		}
+
+		switch mode {
+		case changeDetectionSerial:
+			if state.Lineage == "" || state.Lineage != previous.Lineage || state.Serial != previous.Serial {
+				return true
+			}
+		case changeDetectionContentHash:
+			if state.SHA256 != previous.SHA256 {
+				return true
+			}
+		}
+	}
+
+	for _, backend := range tfOutputs.Spec.Backends {
+		if !recorded[backend.Name] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+var _ = Describe("Change detection", func() {
+	Context("When reconciling a resource with serial change detection", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+		var stateConfigMap *corev1.ConfigMap
+
+		// setState replaces the state in the ConfigMap and clears the last sync time, so the
+		// next reconcile checks the backend
+		setState := func(state string) {
+			stateConfigMap.Data["terraform.tfstate"] = state
+			Expect(k8sClient.Update(ctx, stateConfigMap)).To(Succeed())
+
+			resource.Status.LastSyncTime = nil
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			stateConfigMap = newTestStateConfigMap("serial-state", `{"version":4,"serial":3,"lineage":"serial-lineage",`+
+				`"outputs":{"vpc_id":{"value":"vpc-123","type":"string"}}}`)
+			resource = newTestTerraformOutputs("test-serial-resource", newTestFileBackend("serial-state"))
+			resource.Spec.ChangeDetection = changeDetectionSerial
+			createTestObjects(ctx, stateConfigMap, resource)
+		})
+
+		It("should only sync when the serial changes", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			output := syncedConfigMap(ctx, resource)
+			Expect(output.Data).To(HaveKeyWithValue("vpc_id", "vpc-123"))
+			outputVersion := output.ResourceVersion
+
+			By("Rewriting the state with the same serial")
+			setState(`{"version": 4, "serial": 3, "lineage": "serial-lineage", ` +
+				`"outputs": {"vpc_id": {"value": "vpc-123", "type": "string"}}}`)
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(resource.Status.LastSyncTime).To(BeNil())
+			Expect(resource.Status.SyncStatus).To(Equal("Success"))
+			Expect(syncedConfigMap(ctx, resource).ResourceVersion).To(Equal(outputVersion))
+
+			By("Checking the new file hash was recorded")
+			hasChanges, _, err := controllerReconciler.checkBackendChanges(ctx, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hasChanges).To(BeFalse())
+
+			By("Applying a new serial")
+			setState(`{"version":4,"serial":4,"lineage":"serial-lineage",` +
+				`"outputs":{"vpc_id":{"value":"vpc-456","type":"string"}}}`)
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(resource.Status.LastSyncTime).NotTo(BeNil())
+			Expect(resource.Status.Backends[0].Serial).To(Equal(int64(4)))
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("vpc_id", "vpc-456"))
+		})
+	})
+})
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// clientIdleTimeout is how long an unused backend client is kept in a client cache
+const clientIdleTimeout = 30 * time.Minute
+
+// clientCache caches backend clients per backend configuration, so credentials are resolved
+// once and then refreshed by the SDK instead of on every reconcile
+type clientCache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]*clientCacheEntry[T]
+
+	// creating deduplicates concurrent creations of the same client, which run without
+	// holding mu as loading credentials may be slow
+	creating singleflight.Group
+
+	// now is overridden in tests
+	now func() time.Time
+}
+
+// clientCacheEntry is a cached client and the time it was last used
+type clientCacheEntry[T any] struct {
+	client   T
+	lastUsed time.Time
+}
+
+// get returns the cached client for key, creating it when missing. Clients idle for
+// longer than clientIdleTimeout are evicted. backendType labels the cache metrics.
+func (c *clientCache[T]) get(backendType, key string, create func() (T, error)) (T, error) {
+	if client, ok := c.lookup(backendType, key); ok {
+		clientCacheRequestsTotal.WithLabelValues(backendType, "hit").Inc()
+		return client, nil
+	}
+	clientCacheRequestsTotal.WithLabelValues(backendType, "miss").Inc()
+
+	client, err, _ := c.creating.Do(key, func() (interface{}, error) {
+		// A concurrent caller may have stored the client since the lookup
+		if client, ok := c.lookup(backendType, key); ok {
+			return client, nil
+		}
+
+		client, err := create()
+		if err != nil {
+			return nil, err
+		}
+		c.store(backendType, key, client)
+		return client, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return client.(T), nil
+}
+
+// lookup returns the cached client for key after evicting idle clients. Evicted clients
+// holding connections, such as GCS clients, are closed.
+func (c *clientCache[T]) lookup(backendType, key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.currentTime()
+	for entryKey, entry := range c.entries {
+		if now.Sub(entry.lastUsed) > clientIdleTimeout {
+			delete(c.entries, entryKey)
+			if closer, ok := any(entry.client).(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+	}
+	clientCacheSize.WithLabelValues(backendType).Set(float64(len(c.entries)))
+
+	entry, ok := c.entries[key]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	entry.lastUsed = now
+	return entry.client, true
+}
+
+// store adds a client to the cache
+func (c *clientCache[T]) store(backendType, key string, client T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*clientCacheEntry[T])
+	}
+	c.entries[key] = &clientCacheEntry[T]{client: client, lastUsed: c.currentTime()}
+	clientCacheSize.WithLabelValues(backendType).Set(float64(len(c.entries)))
+}
+
+// currentTime returns the time used to track client usage
+func (c *clientCache[T]) currentTime() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
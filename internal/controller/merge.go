@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const (
+	mergeStrategyLastWins        = "lastWins"
+	mergeStrategyFirstWins       = "firstWins"
+	mergeStrategyError           = "error"
+	mergeStrategyPrefixByBackend = "prefixByBackend"
+	mergeStrategyDeepMerge       = "deepMerge"
+
+	// conditionOutputConflicts reports outputs exported with different values by several backends
+	conditionOutputConflicts = "OutputConflicts"
+
+	// maxConflictsInMessage limits the conflicting outputs listed in the condition message
+	maxConflictsInMessage = 10
+)
+
+// outputConflictError is returned when the error merge strategy finds conflicting outputs
+type outputConflictError struct {
+	conflicts []string
+}
+
+func (e *outputConflictError) Error() string {
+	return fmt.Sprintf("outputs exported with different values by several backends: %s", strings.Join(e.conflicts, ", "))
+}
+
+// asOutputConflictError returns the outputConflictError wrapped by err, if any
+func asOutputConflictError(err error) *outputConflictError {
+	var conflictErr *outputConflictError
+	if errors.As(err, &conflictErr) {
+		return conflictErr
+	}
+	return nil
+}
+
+// backendOutputPrefix returns the prefix of the outputs of a backend with the prefixByBackend strategy
+func backendOutputPrefix(backendName string) string {
+	return backendName + "_"
+}
+
+// prefixOutputKeys prepends the keyPrefix of a backend to the names of its outputs
+func prefixOutputKeys(
+	keyPrefix string,
+	outputs map[string]interface{},
+	sensitiveFlags map[string]bool,
+) (map[string]interface{}, map[string]bool) {
+	if keyPrefix == "" {
+		return outputs, sensitiveFlags
+	}
+
+	prefixed := make(map[string]interface{}, len(outputs))
+	prefixedSensitiveFlags := make(map[string]bool, len(outputs))
+	for name, value := range outputs {
+		prefixed[keyPrefix+name] = value
+		prefixedSensitiveFlags[keyPrefix+name] = sensitiveFlags[name]
+	}
+	return prefixed, prefixedSensitiveFlags
+}
+
+// mergeBackendOutputs merges the outputs of each backend according to the merge strategy and
+// returns the outputs exported with different values by several backends
+func mergeBackendOutputs(strategy string, fetched *fetchedOutputs) ([]string, error) {
+	conflicting := make(map[string]bool)
+
+	for i, outputs := range fetched.backendOutputs {
+		for key, value := range outputs {
+			sensitive := fetched.backendSensitiveFlags[i][key]
+
+			if strategy == mergeStrategyPrefixByBackend {
+				key = backendOutputPrefix(fetched.backendNames[i]) + key
+			}
+
+			existing, exists := fetched.outputs[key]
+			if !exists {
+				fetched.outputs[key] = value
+				fetched.sensitiveFlags[key] = sensitive
+				continue
+			}
+			if !reflect.DeepEqual(existing, value) {
+				conflicting[key] = true
+			}
+
+			switch strategy {
+			case mergeStrategyFirstWins:
+				continue
+			case mergeStrategyDeepMerge:
+				value = deepMergeOutputs(existing, value)
+				sensitive = sensitive || fetched.sensitiveFlags[key]
+			}
+			fetched.outputs[key] = value
+			fetched.sensitiveFlags[key] = sensitive
+		}
+	}
+
+	conflicts := make([]string, 0, len(conflicting))
+	for key := range conflicting {
+		conflicts = append(conflicts, key)
+	}
+	sort.Strings(conflicts)
+
+	if strategy == mergeStrategyError && len(conflicts) > 0 {
+		return conflicts, &outputConflictError{conflicts: conflicts}
+	}
+	return conflicts, nil
+}
+
+// deepMergeOutputs merges map values recursively, the later value winning for anything else
+func deepMergeOutputs(earlier, later interface{}) interface{} {
+	earlierMap, ok := earlier.(map[string]interface{})
+	if !ok {
+		return later
+	}
+	laterMap, ok := later.(map[string]interface{})
+	if !ok {
+		return later
+	}
+
+	merged := make(map[string]interface{}, len(earlierMap)+len(laterMap))
+	for key, value := range earlierMap {
+		merged[key] = value
+	}
+	for key, value := range laterMap {
+		if existing, exists := merged[key]; exists {
+			value = deepMergeOutputs(existing, value)
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// setOutputConflictsCondition records the outputs exported with different values by several backends
+func setOutputConflictsCondition(tfOutputs *outputsv1alpha1.TerraformOutputs, conflicts []string, err error) {
+	if len(conflicts) == 0 {
+		meta.SetStatusCondition(&tfOutputs.Status.Conditions, metav1.Condition{
+			Type:               conditionOutputConflicts,
+			Status:             metav1.ConditionFalse,
+			Reason:             "NoConflicts",
+			Message:            "No output is exported with different values by several backends",
+			ObservedGeneration: tfOutputs.Generation,
+		})
+		return
+	}
+
+	listed := conflicts
+	if len(listed) > maxConflictsInMessage {
+		listed = listed[:maxConflictsInMessage]
+	}
+	message := fmt.Sprintf(
+		"%d outputs are exported with different values by several backends: %s",
+		len(conflicts),
+		strings.Join(listed, ", "),
+	)
+
+	reason := "ConflictsResolved"
+	if err != nil {
+		reason = "MergeFailed"
+	} else {
+		strategy := tfOutputs.Spec.MergeStrategy
+		if strategy == "" {
+			strategy = mergeStrategyLastWins
+		}
+		message += fmt.Sprintf(", resolved with the %s merge strategy", strategy)
+	}
+
+	meta.SetStatusCondition(&tfOutputs.Status.Conditions, metav1.Condition{
+		Type:               conditionOutputConflicts,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: tfOutputs.Generation,
+	})
+}
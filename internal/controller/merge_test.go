@@ -0,0 +1,139 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+var _ = Describe("Merge strategies", func() {
+	// newFetched returns the outputs of a network and an application backend, both exporting
+	// vpc_id and tags, and region with the same value
+	newFetched := func() *fetchedOutputs {
+		return &fetchedOutputs{
+			outputs:        make(map[string]interface{}),
+			sensitiveFlags: make(map[string]bool),
+			backendNames:   []string{"network", "app"},
+			backendOutputs: []map[string]interface{}{
+				{
+					"vpc_id": "vpc-123",
+					"region": "eu-west-1",
+					"tags":   map[string]interface{}{"team": "network", "env": "prod"},
+				},
+				{
+					"vpc_id":   "vpc-456",
+					"region":   "eu-west-1",
+					"tags":     map[string]interface{}{"team": "app"},
+					"db_token": "secret",
+				},
+			},
+			backendSensitiveFlags: []map[string]bool{
+				{"tags": true},
+				{"db_token": true},
+			},
+		}
+	}
+
+	It("should keep the value of the last backend with lastWins", func() {
+		fetched := newFetched()
+		conflicts, err := mergeBackendOutputs(mergeStrategyLastWins, fetched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(Equal([]string{"tags", "vpc_id"}))
+		Expect(fetched.outputs).To(HaveKeyWithValue("vpc_id", "vpc-456"))
+		Expect(fetched.outputs).To(HaveKeyWithValue("tags", map[string]interface{}{"team": "app"}))
+		Expect(fetched.sensitiveFlags).To(HaveKeyWithValue("tags", false))
+		Expect(fetched.sensitiveFlags).To(HaveKeyWithValue("db_token", true))
+	})
+
+	It("should default to lastWins", func() {
+		fetched := newFetched()
+		_, err := mergeBackendOutputs("", fetched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.outputs).To(HaveKeyWithValue("vpc_id", "vpc-456"))
+	})
+
+	It("should keep the value of the first backend with firstWins", func() {
+		fetched := newFetched()
+		conflicts, err := mergeBackendOutputs(mergeStrategyFirstWins, fetched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(Equal([]string{"tags", "vpc_id"}))
+		Expect(fetched.outputs).To(HaveKeyWithValue("vpc_id", "vpc-123"))
+		Expect(fetched.sensitiveFlags).To(HaveKeyWithValue("tags", true))
+	})
+
+	It("should fail on conflicts with error", func() {
+		fetched := newFetched()
+		conflicts, err := mergeBackendOutputs(mergeStrategyError, fetched)
+		Expect(err).To(MatchError(ContainSubstring("tags, vpc_id")))
+		Expect(asOutputConflictError(err)).NotTo(BeNil())
+		Expect(conflicts).To(Equal([]string{"tags", "vpc_id"}))
+	})
+
+	It("should not fail on outputs exported with the same value with error", func() {
+		fetched := newFetched()
+		fetched.backendOutputs[1] = map[string]interface{}{"region": "eu-west-1"}
+		conflicts, err := mergeBackendOutputs(mergeStrategyError, fetched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(BeEmpty())
+	})
+
+	It("should prefix every output with its backend with prefixByBackend", func() {
+		fetched := newFetched()
+		conflicts, err := mergeBackendOutputs(mergeStrategyPrefixByBackend, fetched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(BeEmpty())
+		Expect(fetched.outputs).To(HaveKeyWithValue("network_vpc_id", "vpc-123"))
+		Expect(fetched.outputs).To(HaveKeyWithValue("app_vpc_id", "vpc-456"))
+		Expect(fetched.outputs).NotTo(HaveKey("vpc_id"))
+		Expect(fetched.sensitiveFlags).To(HaveKeyWithValue("app_db_token", true))
+	})
+
+	It("should merge map outputs recursively with deepMerge", func() {
+		fetched := newFetched()
+		conflicts, err := mergeBackendOutputs(mergeStrategyDeepMerge, fetched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(Equal([]string{"tags", "vpc_id"}))
+		Expect(fetched.outputs).To(HaveKeyWithValue("tags", map[string]interface{}{"team": "app", "env": "prod"}))
+		Expect(fetched.outputs).To(HaveKeyWithValue("vpc_id", "vpc-456"))
+		Expect(fetched.sensitiveFlags).To(HaveKeyWithValue("tags", true))
+	})
+
+	It("should report conflicts in the OutputConflicts condition", func() {
+		tfOutputs := &outputsv1alpha1.TerraformOutputs{}
+
+		setOutputConflictsCondition(tfOutputs, nil, nil)
+		condition := meta.FindStatusCondition(tfOutputs.Status.Conditions, conditionOutputConflicts)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+
+		setOutputConflictsCondition(tfOutputs, []string{"vpc_id"}, nil)
+		condition = meta.FindStatusCondition(tfOutputs.Status.Conditions, conditionOutputConflicts)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("ConflictsResolved"))
+		Expect(condition.Message).To(ContainSubstring("vpc_id, resolved with the lastWins merge strategy"))
+
+		err := &outputConflictError{conflicts: []string{"vpc_id"}}
+		setOutputConflictsCondition(tfOutputs, err.conflicts, err)
+		condition = meta.FindStatusCondition(tfOutputs.Status.Conditions, conditionOutputConflicts)
+		Expect(condition.Reason).To(Equal("MergeFailed"))
+	})
+})
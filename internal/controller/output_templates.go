@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"text/template/parse"
+
+	sprig "github.com/go-task/slim-sprig/v3"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// outputTemplateFuncs returns the Sprig functions available to templates. Only hermetic
+// functions are offered, so a template renders the same value on every sync, and cannot read
+// the controller's environment or resolve host names.
+func outputTemplateFuncs() template.FuncMap {
+	funcs := sprig.HermeticTxtFuncMap()
+	for _, name := range []string{"env", "expandenv", "getHostByName"} {
+		delete(funcs, name)
+	}
+	return funcs
+}
+
+// renderOutputTemplate renders a spec.outputs template over the merged outputs. The rendered
+// value is sensitive when the template references a sensitive output.
+func renderOutputTemplate(
+	outputTemplate outputsv1alpha1.OutputTemplate,
+	outputs map[string]interface{},
+	sensitiveFlags map[string]bool,
+) (string, bool, error) {
+	tmpl, err := template.New(outputTemplate.Key).
+		Funcs(outputTemplateFuncs()).
+		Option("missingkey=error").
+		Parse(outputTemplate.Template)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to parse template %s: %w", outputTemplate.Key, err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, outputs); err != nil {
+		return "", false, fmt.Errorf("failed to render template %s: %w", outputTemplate.Key, err)
+	}
+
+	return rendered.String(), outputTemplate.Sensitive || templateReferencesSensitive(tmpl, sensitiveFlags), nil
+}
+
+// templateReferencesSensitive reports whether a template references a sensitive output. A
+// template passing the whole outputs map around, such as toJson . or range ., is considered
+// to reference every output.
+func templateReferencesSensitive(tmpl *template.Template, sensitiveFlags map[string]bool) bool {
+	refs := &templateRefs{names: make(map[string]bool)}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			refs.walk(t.Root)
+		}
+	}
+
+	for name, sensitive := range sensitiveFlags {
+		if sensitive && (refs.dynamic || refs.names[name]) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateRefs collects the outputs referenced by a template
+type templateRefs struct {
+	names   map[string]bool
+	dynamic bool
+}
+
+func (t *templateRefs) walk(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			t.walk(child)
+		}
+	case *parse.ActionNode:
+		t.walk(n.Pipe)
+	case *parse.IfNode:
+		t.walkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		t.walkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		t.walkBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		t.walk(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			t.walk(cmd)
+		}
+	case *parse.CommandNode:
+		// index . "name" references a single output
+		if len(n.Args) == 3 {
+			if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "index" {
+				if _, ok := n.Args[1].(*parse.DotNode); ok {
+					if name, ok := n.Args[2].(*parse.StringNode); ok {
+						t.names[name.Text] = true
+						return
+					}
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			t.walk(arg)
+		}
+	case *parse.FieldNode:
+		t.names[n.Ident[0]] = true
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" {
+			if len(n.Ident) > 1 {
+				t.names[n.Ident[1]] = true
+			} else {
+				t.dynamic = true
+			}
+		}
+	case *parse.ChainNode:
+		t.walk(n.Node)
+	case *parse.DotNode:
+		t.dynamic = true
+	}
+}
+
+func (t *templateRefs) walkBranch(n *parse.BranchNode) {
+	t.walk(n.Pipe)
+	t.walk(n.List)
+	t.walk(n.ElseList)
+}
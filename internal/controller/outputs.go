@@ -0,0 +1,375 @@
+package controller
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const (
+	// defaultFlattenSeparator joins the keys of flattened outputs
+	defaultFlattenSeparator = "."
+
+	// defaultFlattenMaxDepth is the number of nesting levels of flattened outputs
+	defaultFlattenMaxDepth = 5
+)
+
+// outputSelector matches output names against a glob pattern or a regular expression
+type outputSelector func(name string) bool
+
+// compileOutputSelectors compiles spec.outputs selectors. Selectors wrapped in slashes are
+// regular expressions, all others are glob patterns.
+func compileOutputSelectors(selectors []string) ([]outputSelector, error) {
+	compiled := make([]outputSelector, 0, len(selectors))
+	for _, selector := range selectors {
+		if len(selector) > 1 && strings.HasPrefix(selector, "/") && strings.HasSuffix(selector, "/") {
+			re, err := regexp.Compile(selector[1 : len(selector)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid output selector %q: %w", selector, err)
+			}
+			compiled = append(compiled, re.MatchString)
+			continue
+		}
+
+		if _, err := path.Match(selector, ""); err != nil {
+			return nil, fmt.Errorf("invalid output selector %q: %w", selector, err)
+		}
+		compiled = append(compiled, func(name string) bool {
+			matched, _ := path.Match(selector, name)
+			return matched
+		})
+	}
+
+	return compiled, nil
+}
+
+// matchesAnyOutputSelector reports whether name matches any of the selectors
+func matchesAnyOutputSelector(selectors []outputSelector, name string) bool {
+	for _, selector := range selectors {
+		if selector(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// outputFilter selects outputs by the include and exclude selectors of spec.outputs
+type outputFilter struct {
+	include []outputSelector
+	exclude []outputSelector
+}
+
+// newOutputFilter compiles the include and exclude selectors of spec.outputs
+func newOutputFilter(outputsSpec *outputsv1alpha1.OutputsSpec) (*outputFilter, error) {
+	if outputsSpec == nil {
+		return &outputFilter{}, nil
+	}
+
+	include, err := compileOutputSelectors(outputsSpec.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileOutputSelectors(outputsSpec.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	return &outputFilter{include: include, exclude: exclude}, nil
+}
+
+// selects reports whether an output is selected
+func (f *outputFilter) selects(name string) bool {
+	return (len(f.include) == 0 || matchesAnyOutputSelector(f.include, name)) &&
+		!matchesAnyOutputSelector(f.exclude, name)
+}
+
+// apply removes the outputs that are not selected and returns how many were removed
+func (f *outputFilter) apply(outputs map[string]interface{}, sensitiveFlags map[string]bool) int {
+	filtered := 0
+	for name := range outputs {
+		if f.selects(name) {
+			continue
+		}
+		delete(outputs, name)
+		delete(sensitiveFlags, name)
+		filtered++
+	}
+	return filtered
+}
+
+// processOutputs applies spec.outputs to the fetched outputs: it drops the outputs that are
+// not selected, flattens the nested ones, renames the mapped ones, transforms the keys of the
+// others and adds the rendered templates. It returns the outputs by key, their sensitivity and the number of
+// dropped outputs. The fetched outputs are left untouched, so they can be processed for each target.
+func processOutputs(
+	outputsSpec *outputsv1alpha1.OutputsSpec,
+	fetched *fetchedOutputs,
+) (map[string]interface{}, map[string]bool, int, error) {
+	outputs, sensitiveFlags := maps.Clone(fetched.outputs), maps.Clone(fetched.sensitiveFlags)
+
+	// Templates are rendered over all merged outputs, so they can use outputs that are not synced
+	var rendered []renderedTemplate
+	if outputsSpec != nil {
+		for _, outputTemplate := range outputsSpec.Templates {
+			value, sensitive, err := renderOutputTemplate(outputTemplate, outputs, sensitiveFlags)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			rendered = append(rendered, renderedTemplate{key: outputTemplate.Key, value: value, sensitive: sensitive})
+		}
+	}
+
+	filter, err := newOutputFilter(outputsSpec)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	filteredCount := filter.apply(outputs, sensitiveFlags)
+	if outputsSpec == nil {
+		return outputs, sensitiveFlags, filteredCount, nil
+	}
+
+	outputs, sensitiveFlags, err = flattenOutputs(outputsSpec.Flatten, outputs, sensitiveFlags)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if outputsSpec.Flatten == nil && len(outputsSpec.Mappings) == 0 &&
+		outputsSpec.Transform == nil && len(outputsSpec.Templates) == 0 {
+		return outputs, sensitiveFlags, filteredCount, nil
+	}
+
+	keyed := make(map[string]interface{}, len(outputs))
+	keyedSensitiveFlags := make(map[string]bool, len(outputs))
+	sources := make(map[string]string, len(outputs))
+	add := func(source, key string, value interface{}, sensitive bool) error {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return fmt.Errorf("invalid key %q for output %s: %s", key, source, strings.Join(errs, ", "))
+		}
+		if existing, exists := sources[key]; exists {
+			return fmt.Errorf("outputs %s and %s are both written to key %q", existing, source, key)
+		}
+		sources[key] = source
+		keyed[key] = value
+		keyedSensitiveFlags[key] = sensitive
+		return nil
+	}
+
+	// Mappings read the selected outputs, either merged or of a single backend
+	mapped := make(map[string]bool, len(outputsSpec.Mappings))
+	for _, mapping := range outputsSpec.Mappings {
+		value, exists := outputs[mapping.From]
+		sensitive := sensitiveFlags[mapping.From]
+		source := mapping.From
+		if mapping.Backend != "" {
+			backend := slices.Index(fetched.backendNames, mapping.Backend)
+			if backend < 0 {
+				return nil, nil, 0, fmt.Errorf(
+					"mapping of output %s references backend %s, which is not configured",
+					mapping.From,
+					mapping.Backend,
+				)
+			}
+			backendOutputs, backendSensitiveFlags, err := selectBackendOutputs(outputsSpec, filter, fetched, backend)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			value, exists = backendOutputs[mapping.From]
+			sensitive = backendSensitiveFlags[mapping.From]
+			source = fmt.Sprintf("%s of backend %s", mapping.From, mapping.Backend)
+		}
+		mapped[mapping.From] = true
+
+		// Outputs removed from the state are removed from the target, mapped or not
+		if !exists {
+			continue
+		}
+		if err := add(source, mapping.To, value, sensitive); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		if !mapped[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := transformOutputKey(outputsSpec.Transform, name)
+		if err := add(name, key, outputs[name], sensitiveFlags[name]); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	for _, value := range rendered {
+		if err := add("template "+value.key, value.key, value.value, value.sensitive); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	return keyed, keyedSensitiveFlags, filteredCount, nil
+}
+
+// selectBackendOutputs returns the selected and flattened outputs of a single backend
+func selectBackendOutputs(
+	outputsSpec *outputsv1alpha1.OutputsSpec,
+	filter *outputFilter,
+	fetched *fetchedOutputs,
+	backend int,
+) (map[string]interface{}, map[string]bool, error) {
+	outputs := make(map[string]interface{}, len(fetched.backendOutputs[backend]))
+	sensitiveFlags := make(map[string]bool, len(fetched.backendOutputs[backend]))
+	for name, value := range fetched.backendOutputs[backend] {
+		outputs[name] = value
+		sensitiveFlags[name] = fetched.backendSensitiveFlags[backend][name]
+	}
+	filter.apply(outputs, sensitiveFlags)
+
+	return flattenOutputs(outputsSpec.Flatten, outputs, sensitiveFlags)
+}
+
+// flattenOutputs expands the map and list outputs selected by spec.outputs.flatten into a key
+// per nested value. Nested values inherit the sensitivity of their output.
+func flattenOutputs(
+	flatten *outputsv1alpha1.FlattenSpec,
+	outputs map[string]interface{},
+	sensitiveFlags map[string]bool,
+) (map[string]interface{}, map[string]bool, error) {
+	if flatten == nil {
+		return outputs, sensitiveFlags, nil
+	}
+
+	selectors, err := compileOutputSelectors(flatten.Outputs)
+	if err != nil {
+		return nil, nil, err
+	}
+	separator := flatten.Separator
+	if separator == "" {
+		separator = defaultFlattenSeparator
+	}
+	maxDepth := flatten.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultFlattenMaxDepth
+	}
+
+	flattened := make(map[string]interface{}, len(outputs))
+	flattenedSensitiveFlags := make(map[string]bool, len(outputs))
+	sources := make(map[string]string, len(outputs))
+	add := func(name, key string, value interface{}) error {
+		if existing, exists := sources[key]; exists {
+			return fmt.Errorf("outputs %s and %s are both flattened to key %q", existing, name, key)
+		}
+		sources[key] = name
+		flattened[key] = value
+		flattenedSensitiveFlags[key] = sensitiveFlags[name]
+		return nil
+	}
+
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if len(selectors) > 0 && !matchesAnyOutputSelector(selectors, name) {
+			if err := add(name, name, outputs[name]); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		// Values nested deeper than maxDepth, and empty maps and lists, are kept as a single key
+		var expand func(key string, value interface{}, depth int) error
+		expand = func(key string, value interface{}, depth int) error {
+			if depth > maxDepth {
+				return add(name, key, value)
+			}
+			switch v := value.(type) {
+			case map[string]interface{}:
+				if len(v) == 0 {
+					return add(name, key, value)
+				}
+				keys := make([]string, 0, len(v))
+				for k := range v {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					if err := expand(key+separator+k, v[k], depth+1); err != nil {
+						return err
+					}
+				}
+				return nil
+			case []interface{}:
+				if len(v) == 0 {
+					return add(name, key, value)
+				}
+				for i, item := range v {
+					if err := expand(key+separator+strconv.Itoa(i), item, depth+1); err != nil {
+						return err
+					}
+				}
+				return nil
+			default:
+				return add(name, key, value)
+			}
+		}
+		if err := expand(name, outputs[name], 1); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return flattened, flattenedSensitiveFlags, nil
+}
+
+// renderedTemplate is the value of a spec.outputs template
+type renderedTemplate struct {
+	key       string
+	value     string
+	sensitive bool
+}
+
+// transformOutputKey returns the key an output is written to according to spec.outputs.transform
+func transformOutputKey(transform *outputsv1alpha1.OutputTransformSpec, name string) string {
+	if transform == nil {
+		return name
+	}
+
+	switch transform.Case {
+	case "Upper":
+		name = strings.ToUpper(name)
+	case "ScreamingSnake":
+		name = screamingSnakeCase(name)
+	}
+
+	return transform.Prefix + name + transform.Suffix
+}
+
+// screamingSnakeCase converts snake_case, kebab-case, dotted and camelCase names to SCREAMING_SNAKE_CASE
+func screamingSnakeCase(name string) string {
+	var b strings.Builder
+	var previous rune
+	for i, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			r = '_'
+		case i > 0 && unicode.IsUpper(r) && (unicode.IsLower(previous) || unicode.IsDigit(previous)):
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		previous = r
+	}
+	return b.String()
+}
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const (
+	// conditionStateDecrypted reports whether OpenTofu encrypted state could be decrypted
+	conditionStateDecrypted = "StateDecrypted"
+
+	// encryptedStateVersion is the only OpenTofu encrypted state format
+	encryptedStateVersion = "v0"
+)
+
+// stateDecryptionError is returned when encrypted state cannot be decrypted, so the
+// reconciler can report it as a condition rather than a parse error
+type stateDecryptionError struct {
+	message string
+}
+
+func (e *stateDecryptionError) Error() string {
+	return e.message
+}
+
+// asStateDecryptionError returns the stateDecryptionError wrapped by err, if any
+func asStateDecryptionError(err error) *stateDecryptionError {
+	var decryptionErr *stateDecryptionError
+	if errors.As(err, &decryptionErr) {
+		return decryptionErr
+	}
+	return nil
+}
+
+// encryptedState is the envelope OpenTofu writes in place of the state when state
+// encryption is enabled
+type encryptedState struct {
+	Meta    map[string][]byte `json:"meta"`
+	Data    []byte            `json:"encrypted_data"`
+	Version string            `json:"encryption_version"`
+}
+
+// pbkdf2Metadata is the metadata the OpenTofu pbkdf2 key provider stores in the envelope
+type pbkdf2Metadata struct {
+	Salt         []byte `json:"salt"`
+	Iterations   int    `json:"iterations"`
+	HashFunction string `json:"hash_function"`
+	KeyLength    int    `json:"key_length"`
+}
+
+// stateDecryption holds the resolved key material of a backend
+type stateDecryption struct {
+	passphrase string
+	key        []byte
+	aad        []byte
+}
+
+type stateDecryptionContextKey struct{}
+
+// withStateDecryption returns a context carrying the key material used by parseTerraformOutputs
+func withStateDecryption(ctx context.Context, decryption *stateDecryption) context.Context {
+	return context.WithValue(ctx, stateDecryptionContextKey{}, decryption)
+}
+
+// resolveStateDecryption reads the key material of a backend from its Secret
+func (r *TerraformOutputsReconciler) resolveStateDecryption(
+	ctx context.Context,
+	encryptionSpec *outputsv1alpha1.StateEncryptionSpec,
+	namespace string,
+) (*stateDecryption, error) {
+	if encryptionSpec == nil {
+		return nil, nil
+	}
+	if (encryptionSpec.PassphraseSecretRef == nil) == (encryptionSpec.KeySecretRef == nil) {
+		return nil, fmt.Errorf("exactly one of passphraseSecretRef or keySecretRef must be specified")
+	}
+
+	decryption := &stateDecryption{aad: []byte(encryptionSpec.AAD)}
+
+	if encryptionSpec.PassphraseSecretRef != nil {
+		passphrase, err := r.readSecretKey(ctx, namespace, *encryptionSpec.PassphraseSecretRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read state encryption passphrase: %w", err)
+		}
+		decryption.passphrase = strings.TrimSpace(string(passphrase))
+		return decryption, nil
+	}
+
+	key, err := r.readSecretKey(ctx, namespace, *encryptionSpec.KeySecretRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state encryption key: %w", err)
+	}
+	if !validAESKeyLength(len(key)) {
+		decoded, decodeErr := base64.StdEncoding.DecodeString(strings.TrimSpace(string(key)))
+		if decodeErr != nil || !validAESKeyLength(len(decoded)) {
+			return nil, fmt.Errorf("state encryption key must be 16, 24 or 32 bytes, raw or base64 encoded")
+		}
+		key = decoded
+	}
+	decryption.key = key
+
+	return decryption, nil
+}
+
+// validAESKeyLength reports whether n is an AES-128, AES-192 or AES-256 key length
+func validAESKeyLength(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// decryptState decrypts OpenTofu encrypted state with the key material from the context.
+// Unencrypted state is returned unchanged.
+func decryptState(ctx context.Context, body []byte) ([]byte, error) {
+	var envelope encryptedState
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Version == "" {
+		return body, nil
+	}
+
+	decryption, _ := ctx.Value(stateDecryptionContextKey{}).(*stateDecryption)
+	if decryption == nil {
+		return nil, &stateDecryptionError{
+			message: "state is encrypted with OpenTofu state encryption, but no encryption is configured for the backend",
+		}
+	}
+	if envelope.Version != encryptedStateVersion {
+		return nil, &stateDecryptionError{
+			message: fmt.Sprintf("unsupported OpenTofu state encryption version %q", envelope.Version),
+		}
+	}
+
+	if decryption.key != nil {
+		plaintext, err := openAESGCM(decryption.key, envelope.Data, decryption.aad)
+		if err != nil {
+			return nil, &stateDecryptionError{message: "failed to decrypt state: wrong encryption key"}
+		}
+		return plaintext, nil
+	}
+
+	// Derive the key from the passphrase with the pbkdf2 metadata stored in the envelope
+	found := false
+	for _, rawMetadata := range envelope.Meta {
+		var metadata pbkdf2Metadata
+		if err := json.Unmarshal(rawMetadata, &metadata); err != nil ||
+			len(metadata.Salt) == 0 || metadata.Iterations <= 0 {
+			continue
+		}
+		found = true
+
+		var hashFunction func() hash.Hash
+		switch metadata.HashFunction {
+		case "sha256":
+			hashFunction = sha256.New
+		case "sha512", "":
+			hashFunction = sha512.New
+		default:
+			continue
+		}
+
+		key, err := pbkdf2.Key(
+			hashFunction,
+			decryption.passphrase,
+			metadata.Salt,
+			metadata.Iterations,
+			metadata.KeyLength,
+		)
+		if err != nil {
+			continue
+		}
+		if plaintext, err := openAESGCM(key, envelope.Data, decryption.aad); err == nil {
+			return plaintext, nil
+		}
+	}
+
+	if !found {
+		return nil, &stateDecryptionError{message: "encrypted state has no pbkdf2 key provider metadata"}
+	}
+	return nil, &stateDecryptionError{message: "failed to decrypt state: wrong passphrase"}
+}
+
+// openAESGCM decrypts data written by the OpenTofu aes_gcm method: the nonce followed by
+// the sealed ciphertext
+func openAESGCM(key, data, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
+
+// setStateDecryptedCondition records the outcome of decrypting the state of all backends
+func setStateDecryptedCondition(tfOutputs *outputsv1alpha1.TerraformOutputs, err error) {
+	encrypted := false
+	for _, backend := range tfOutputs.Spec.Backends {
+		if backend.Encryption != nil {
+			encrypted = true
+		}
+	}
+
+	switch {
+	case err != nil:
+		meta.SetStatusCondition(&tfOutputs.Status.Conditions, metav1.Condition{
+			Type:               conditionStateDecrypted,
+			Status:             metav1.ConditionFalse,
+			Reason:             "DecryptionFailed",
+			Message:            err.Error(),
+			ObservedGeneration: tfOutputs.Generation,
+		})
+	case encrypted:
+		meta.SetStatusCondition(&tfOutputs.Status.Conditions, metav1.Condition{
+			Type:               conditionStateDecrypted,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Decrypted",
+			Message:            "Encrypted state was decrypted",
+			ObservedGeneration: tfOutputs.Generation,
+		})
+	default:
+		meta.RemoveStatusCondition(&tfOutputs.Status.Conditions, conditionStateDecrypted)
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// encryptTofuState encrypts state like the OpenTofu aes_gcm method, using a pbkdf2 key
+// derived from passphrase, and wraps it in the encrypted state envelope
+func encryptTofuState(passphrase, state string) []byte {
+	salt := make([]byte, 32)
+	_, err := rand.Read(salt)
+	Expect(err).NotTo(HaveOccurred())
+
+	metadata := pbkdf2Metadata{Salt: salt, Iterations: 1000, HashFunction: "sha512", KeyLength: 32}
+	key, err := pbkdf2.Key(sha512.New, passphrase, salt, metadata.Iterations, metadata.KeyLength)
+	Expect(err).NotTo(HaveOccurred())
+
+	block, err := aes.NewCipher(key)
+	Expect(err).NotTo(HaveOccurred())
+	gcm, err := cipher.NewGCM(block)
+	Expect(err).NotTo(HaveOccurred())
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	Expect(err).NotTo(HaveOccurred())
+
+	rawMetadata, err := json.Marshal(metadata)
+	Expect(err).NotTo(HaveOccurred())
+	envelope, err := json.Marshal(encryptedState{
+		Meta:    map[string][]byte{"key_provider.pbkdf2.passphrase": rawMetadata},
+		Data:    gcm.Seal(nonce, nonce, []byte(state), nil),
+		Version: encryptedStateVersion,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	return envelope
+}
+
+var _ = Describe("State encryption", func() {
+	It("should decrypt state with a raw AES-GCM key", func() {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		Expect(err).NotTo(HaveOccurred())
+
+		block, err := aes.NewCipher(key)
+		Expect(err).NotTo(HaveOccurred())
+		gcm, err := cipher.NewGCM(block)
+		Expect(err).NotTo(HaveOccurred())
+		nonce := make([]byte, gcm.NonceSize())
+		envelope, err := json.Marshal(encryptedState{
+			Data:    gcm.Seal(nonce, nonce, []byte(`{"outputs":{}}`), []byte("aad")),
+			Version: encryptedStateVersion,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx := withStateDecryption(context.Background(), &stateDecryption{key: key, aad: []byte("aad")})
+		plaintext, err := decryptState(ctx, envelope)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plaintext)).To(Equal(`{"outputs":{}}`))
+
+		ctx = withStateDecryption(context.Background(), &stateDecryption{key: make([]byte, 32)})
+		_, err = decryptState(ctx, envelope)
+		Expect(asStateDecryptionError(err)).NotTo(BeNil())
+	})
+
+	It("should leave unencrypted state alone and reject encrypted state without a key", func() {
+		plaintext, err := decryptState(context.Background(), []byte(`{"outputs":{}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plaintext)).To(Equal(`{"outputs":{}}`))
+
+		_, err = decryptState(context.Background(), encryptTofuState("correct horse battery staple", `{}`))
+		Expect(err).To(MatchError(ContainSubstring("no encryption is configured")))
+	})
+
+	Context("When reconciling a resource with encrypted state", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+		var passphraseSecret *corev1.Secret
+
+		BeforeEach(func() {
+			passphraseSecret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "tofu-passphrase",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{"passphrase": []byte("wrong passphrase")},
+			}
+
+			backend := newTestFileBackend("encrypted-state")
+			backend.Encryption = &outputsv1alpha1.StateEncryptionSpec{
+				PassphraseSecretRef: &outputsv1alpha1.SecretKeyReference{
+					Name: "tofu-passphrase",
+					Key:  "passphrase",
+				},
+			}
+			resource = newTestTerraformOutputs("test-encrypted-resource", backend)
+
+			createTestObjects(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "encrypted-state",
+					Namespace: "default",
+				},
+				BinaryData: map[string][]byte{
+					"terraform.tfstate": encryptTofuState(
+						"correct horse battery staple",
+						`{"outputs":{"api_url":{"value":"https://api.example.com","sensitive":false}}}`,
+					),
+				},
+			}, passphraseSecret, resource)
+		})
+
+		It("should report a wrong passphrase as a condition and decrypt with the right one", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).NotTo(Succeed())
+			condition := meta.FindStatusCondition(resource.Status.Conditions, conditionStateDecrypted)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("DecryptionFailed"))
+			Expect(condition.Message).To(ContainSubstring("wrong passphrase"))
+
+			By("Fixing the passphrase")
+			passphraseSecret.Data["passphrase"] = []byte("correct horse battery staple")
+			Expect(k8sClient.Update(ctx, passphraseSecret)).To(Succeed())
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("api_url", "https://api.example.com"))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, conditionStateDecrypted)).To(BeTrue())
+		})
+	})
+})
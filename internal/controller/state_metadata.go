@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+// supportedStateVersion is the state file format written by Terraform >= 0.12 and OpenTofu
+const supportedStateVersion = 4
+
+// validateStateVersion rejects state file formats other than the supported one. States without
+// a version are accepted, as they only carry outputs.
+func validateStateVersion(version int) error {
+	switch {
+	case version == 0 || version == supportedStateVersion:
+		return nil
+	case version < supportedStateVersion:
+		return fmt.Errorf(
+			"unsupported Terraform state version %d, state written by Terraform < 0.12 must be upgraded by running Terraform >= 0.12",
+			version,
+		)
+	default:
+		return fmt.Errorf(
+			"unsupported Terraform state version %d, only version %d is supported",
+			version,
+			supportedStateVersion,
+		)
+	}
+}
+
+// stateRecorder collects the metadata of the state files parsed for a backend
+type stateRecorder struct {
+	states []outputsv1alpha1.BackendStatus
+}
+
+type stateRecorderContextKey struct{}
+
+type stateKeyContextKey struct{}
+
+// withStateRecorder returns a context in which parseTerraformOutputs records state metadata
+func withStateRecorder(ctx context.Context, recorder *stateRecorder) context.Context {
+	return context.WithValue(ctx, stateRecorderContextKey{}, recorder)
+}
+
+// withStateKey returns a context identifying the state file being parsed, for backends
+// reading several state files
+func withStateKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, stateKeyContextKey{}, key)
+}
+
+// recordState records the metadata and content hash of a parsed state file in the recorder of
+// the context, if any
+func recordState(ctx context.Context, tfState TerraformState, body []byte) {
+	recorder, _ := ctx.Value(stateRecorderContextKey{}).(*stateRecorder)
+	if recorder == nil {
+		return
+	}
+
+	key, _ := ctx.Value(stateKeyContextKey{}).(string)
+	sum := sha256.Sum256(body)
+	recorder.states = append(recorder.states, outputsv1alpha1.BackendStatus{
+		State:            key,
+		Lineage:          tfState.Lineage,
+		Serial:           tfState.Serial,
+		TerraformVersion: tfState.TerraformVersion,
+		StateVersion:     tfState.Version,
+		SHA256:           hex.EncodeToString(sum[:]),
+	})
+}
+
+// checkLineage returns an error if the lineage of a state file differs from the one seen in the
+// last successful sync of the same spec generation, which means the backend now points at a
+// different Terraform stack. Changing the spec accepts the new lineage.
+func checkLineage(tfOutputs *outputsv1alpha1.TerraformOutputs, state outputsv1alpha1.BackendStatus) error {
+	if tfOutputs.Status.ObservedGeneration != tfOutputs.Generation || state.Lineage == "" {
+		return nil
+	}
+
+	for _, previous := range tfOutputs.Status.Backends {
+		if previous.Name != state.Name || previous.Type != state.Type || previous.State != state.State {
+			continue
+		}
+		if previous.Lineage != "" && previous.Lineage != state.Lineage {
+			return fmt.Errorf(
+				"state lineage changed from %s to %s, the backend now points at a different state; "+
+					"update the TerraformOutputs spec to accept it",
+				previous.Lineage,
+				state.Lineage,
+			)
+		}
+	}
+
+	return nil
+}
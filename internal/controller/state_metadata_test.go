@@ -0,0 +1,88 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+var _ = Describe("State metadata", func() {
+	It("should reject unsupported state versions", func() {
+		_, _, err := parseTerraformOutputs(context.Background(), []byte(`{"version":3,"modules":[]}`))
+		Expect(err).To(MatchError(ContainSubstring("unsupported Terraform state version 3")))
+
+		_, _, err = parseTerraformOutputs(context.Background(), []byte(`{"version":5,"outputs":{}}`))
+		Expect(err).To(MatchError(ContainSubstring("only version 4 is supported")))
+	})
+
+	Context("When reconciling a resource", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+		var stateConfigMap *corev1.ConfigMap
+
+		BeforeEach(func() {
+			stateConfigMap = newTestStateConfigMap("lineage-state",
+				`{"version":4,"terraform_version":"1.9.5","serial":7,`+
+					`"lineage":"3f8c2a6e-0000-4000-8000-000000000001",`+
+					`"outputs":{"vpc_id":{"value":"vpc-123","type":"string"}}}`,
+			)
+			resource = newTestTerraformOutputs("test-lineage-resource", newTestFileBackend("lineage-state"))
+			createTestObjects(ctx, stateConfigMap, resource)
+		})
+
+		It("should record lineage and serial and reject a lineage change", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(resource.Status.Backends).To(HaveLen(1))
+			Expect(resource.Status.Backends[0].Name).To(Equal("file"))
+			Expect(resource.Status.Backends[0].Type).To(Equal("file"))
+			Expect(resource.Status.Backends[0].Lineage).To(Equal("3f8c2a6e-0000-4000-8000-000000000001"))
+			Expect(resource.Status.Backends[0].Serial).To(Equal(int64(7)))
+			Expect(resource.Status.Backends[0].TerraformVersion).To(Equal("1.9.5"))
+			Expect(resource.Status.Backends[0].StateVersion).To(Equal(4))
+
+			By("Pointing the backend at a state with a different lineage")
+			stateConfigMap.Data["terraform.tfstate"] = `{"version":4,"terraform_version":"1.9.5","serial":1,` +
+				`"lineage":"3f8c2a6e-0000-4000-8000-000000000002",` +
+				`"outputs":{"vpc_id":{"value":"vpc-456","type":"string"}}}`
+			Expect(k8sClient.Update(ctx, stateConfigMap)).To(Succeed())
+
+			resource.Status.LastSyncTime = nil
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).
+				To(MatchError(ContainSubstring("state lineage changed")))
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("vpc_id", "vpc-123"))
+
+			By("Accepting the new lineage with a spec change")
+			resource.Spec.SyncInterval = "10m"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(resource.Status.Backends).To(HaveLen(1))
+			Expect(resource.Status.Backends[0].Lineage).To(Equal("3f8c2a6e-0000-4000-8000-000000000002"))
+			Expect(resource.Status.Backends[0].Serial).To(Equal(int64(1)))
+		})
+	})
+})
@@ -26,12 +26,17 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
 	// +kubebuilder:scaffold:imports
@@ -94,3 +99,130 @@ var _ = AfterSuite(func() {
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
 })
+
+// newTestReconciler returns a reconciler using the test client
+func newTestReconciler() *TerraformOutputsReconciler {
+	return &TerraformOutputsReconciler{
+		Client: k8sClient,
+		Scheme: k8sClient.Scheme(),
+	}
+}
+
+// newTestTerraformOutputs returns a TerraformOutputs in the default namespace syncing the
+// backends to a ConfigMap and a Secret named after it
+func newTestTerraformOutputs(name string, backends ...outputsv1alpha1.BackendSpec) *outputsv1alpha1.TerraformOutputs {
+	return &outputsv1alpha1.TerraformOutputs{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: outputsv1alpha1.TerraformOutputsSpec{
+			SyncInterval: "5m",
+			Backends:     backends,
+			Target: outputsv1alpha1.TargetSpec{
+				Namespace:     "default",
+				ConfigMapName: name + "-outputs",
+				SecretName:    name + "-secrets",
+			},
+		},
+	}
+}
+
+// newTestStateConfigMap returns a ConfigMap in the default namespace holding a state read by
+// the backend returned by newTestFileBackend
+func newTestStateConfigMap(name, state string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Data: map[string]string{"terraform.tfstate": state},
+	}
+}
+
+// newTestFileBackend returns a file backend reading the state from a ConfigMap
+func newTestFileBackend(configMapName string) outputsv1alpha1.BackendSpec {
+	return outputsv1alpha1.BackendSpec{
+		Name: "file",
+		File: &outputsv1alpha1.FileSpec{
+			ConfigMapRef: &outputsv1alpha1.ConfigMapKeyReference{
+				Name: configMapName,
+				Key:  "terraform.tfstate",
+			},
+		},
+	}
+}
+
+// createTestObjects creates objects for the current spec and deletes them when it ends.
+// TerraformOutputs are deleted through their finalizer, which also deletes the ConfigMaps and
+// Secrets synced for them.
+func createTestObjects(ctx context.Context, objs ...client.Object) {
+	for _, obj := range objs {
+		Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+		DeferCleanup(func() {
+			deleteTestObject(ctx, obj)
+		})
+	}
+}
+
+// deleteTestObject deletes an object created by createTestObjects, if it still exists
+func deleteTestObject(ctx context.Context, obj client.Object) {
+	if _, ok := obj.(*outputsv1alpha1.TerraformOutputs); !ok {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, obj))).To(Succeed())
+		return
+	}
+
+	resource := &outputsv1alpha1.TerraformOutputs{}
+	err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), resource)
+	if errors.IsNotFound(err) {
+		return
+	}
+	Expect(err).NotTo(HaveOccurred())
+	deleteTerraformOutputs(ctx, resource)
+}
+
+// deleteTerraformOutputs deletes a TerraformOutputs and reconciles the deletion, so its finalizer
+// runs as the controller would run it
+func deleteTerraformOutputs(ctx context.Context, resource *outputsv1alpha1.TerraformOutputs) {
+	Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+
+	_, err := newTestReconciler().Reconcile(ctx, reconcile.Request{
+		NamespacedName: client.ObjectKeyFromObject(resource),
+	})
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// reconcileTestTerraformOutputs reconciles a TerraformOutputs, refreshes it with the stored
+// object and returns the reconcile error
+func reconcileTestTerraformOutputs(
+	ctx context.Context,
+	controllerReconciler *TerraformOutputsReconciler,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+) error {
+	_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+		NamespacedName: client.ObjectKeyFromObject(tfOutputs),
+	})
+	Expect(client.IgnoreNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(tfOutputs), tfOutputs))).
+		To(Succeed())
+	return err
+}
+
+// syncedConfigMap returns the ConfigMap written to spec.target of a TerraformOutputs
+func syncedConfigMap(ctx context.Context, tfOutputs *outputsv1alpha1.TerraformOutputs) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{}
+	Expect(k8sClient.Get(ctx, types.NamespacedName{
+		Name:      tfOutputs.Spec.Target.ConfigMapName,
+		Namespace: tfOutputs.Spec.Target.Namespace,
+	}, configMap)).To(Succeed())
+	return configMap
+}
+
+// syncedSecret returns the Secret written to spec.target of a TerraformOutputs
+func syncedSecret(ctx context.Context, tfOutputs *outputsv1alpha1.TerraformOutputs) *corev1.Secret {
+	secret := &corev1.Secret{}
+	Expect(k8sClient.Get(ctx, types.NamespacedName{
+		Name:      tfOutputs.Spec.Target.SecretName,
+		Namespace: tfOutputs.Spec.Target.Namespace,
+	}, secret)).To(Succeed())
+	return secret
+}
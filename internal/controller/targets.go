@@ -0,0 +1,460 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+const (
+	// sourceLabel and sourceNamespaceLabel identify the TerraformOutputs that wrote a ConfigMap or Secret
+	sourceLabel          = "terraform-outputs/source"
+	sourceNamespaceLabel = "terraform-outputs/source-namespace"
+
+	// targetCleanupFinalizer lets the controller delete the ConfigMaps and Secrets of a TerraformOutputs
+	// before it goes away. Owner references cannot cross namespaces, so garbage collection alone
+	// would leave the copies in other namespaces behind.
+	targetCleanupFinalizer = "tfout.wibrow.net/target-cleanup"
+
+	// createdByAnnotation records the TerraformOutputs that created a ConfigMap or Secret, as
+	// <namespace>/<name>. Only resources it created are deleted with a TerraformOutputs.
+	createdByAnnotation = "tfout.wibrow.net/created-by"
+)
+
+// targetResourceLabels returns the labels of the ConfigMaps and Secrets written by a TerraformOutputs
+func targetResourceLabels(tfOutputs *outputsv1alpha1.TerraformOutputs) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by": "tfout",
+		sourceLabel:                    tfOutputs.Name,
+		sourceNamespaceLabel:           tfOutputs.Namespace,
+	}
+}
+
+// isTargetResourceOf reports whether a ConfigMap or Secret is labeled as written by the TerraformOutputs.
+// Resources written before the source-namespace label was added only carry the source label and
+// live next to their TerraformOutputs.
+func isTargetResourceOf(obj client.Object, tfOutputs *outputsv1alpha1.TerraformOutputs) bool {
+	objLabels := obj.GetLabels()
+	if objLabels[sourceLabel] != tfOutputs.Name {
+		return false
+	}
+	if namespace := objLabels[sourceNamespaceLabel]; namespace != "" {
+		return namespace == tfOutputs.Namespace
+	}
+	return obj.GetNamespace() == tfOutputs.Namespace
+}
+
+// targetResourceAnnotations returns the annotations of the ConfigMaps and Secrets created by a TerraformOutputs
+func targetResourceAnnotations(tfOutputs *outputsv1alpha1.TerraformOutputs) map[string]string {
+	return map[string]string{
+		createdByAnnotation: tfOutputs.Namespace + "/" + tfOutputs.Name,
+	}
+}
+
+// isCreatedBy reports whether a ConfigMap or Secret was created by the TerraformOutputs. Resources
+// created before the created-by annotation was added are recognized by their owner reference.
+func isCreatedBy(obj client.Object, tfOutputs *outputsv1alpha1.TerraformOutputs) bool {
+	if createdBy, ok := obj.GetAnnotations()[createdByAnnotation]; ok {
+		return createdBy == tfOutputs.Namespace+"/"+tfOutputs.Name
+	}
+	return metav1.IsControlledBy(obj, tfOutputs)
+}
+
+// checkTargetResourceOwnership returns an error if an existing ConfigMap or Secret was not written
+// by the TerraformOutputs, so the outputs never overwrite a resource managed by someone else or by
+// another TerraformOutputs. Resources written to other namespaces before the source-namespace label
+// was added are recognized by their owner reference, and relabeled when written.
+func checkTargetResourceOwnership(kind string, obj client.Object, tfOutputs *outputsv1alpha1.TerraformOutputs) error {
+	if isTargetResourceOf(obj, tfOutputs) {
+		return nil
+	}
+	if obj.GetLabels()[sourceNamespaceLabel] == "" && metav1.IsControlledBy(obj, tfOutputs) {
+		return nil
+	}
+	if source := obj.GetLabels()[sourceLabel]; source != "" {
+		namespace := obj.GetLabels()[sourceNamespaceLabel]
+		if namespace == "" {
+			namespace = obj.GetNamespace()
+		}
+		return fmt.Errorf("%s %s already exists and is managed by TerraformOutputs %s/%s",
+			kind, client.ObjectKeyFromObject(obj), namespace, source)
+	}
+	return fmt.Errorf("%s %s already exists and is not managed by tfout", kind, client.ObjectKeyFromObject(obj))
+}
+
+// mergeTargetResourceLabels adds the labels tracking the TerraformOutputs to the labels of an
+// existing ConfigMap or Secret, keeping any other labels
+func mergeTargetResourceLabels(obj client.Object, tfOutputs *outputsv1alpha1.TerraformOutputs) {
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = make(map[string]string, 3)
+	}
+	for key, value := range targetResourceLabels(tfOutputs) {
+		objLabels[key] = value
+	}
+	obj.SetLabels(objLabels)
+}
+
+// specTargets returns the targets of a TerraformOutputs: spec.target, unless it names neither
+// a ConfigMap nor a Secret, followed by spec.targets
+func specTargets(tfOutputs *outputsv1alpha1.TerraformOutputs) []outputsv1alpha1.TargetSpec {
+	targets := make([]outputsv1alpha1.TargetSpec, 0, len(tfOutputs.Spec.Targets)+1)
+	if tfOutputs.Spec.Target.ConfigMapName != "" || tfOutputs.Spec.Target.SecretName != "" {
+		targets = append(targets, tfOutputs.Spec.Target)
+	}
+	return append(targets, tfOutputs.Spec.Targets...)
+}
+
+// resolveTargets expands the targets selecting namespaces by label into a target per matching
+// namespace. Namespaces being deleted are skipped.
+func (r *TerraformOutputsReconciler) resolveTargets(
+	ctx context.Context,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+) ([]outputsv1alpha1.TargetSpec, error) {
+	var targets []outputsv1alpha1.TargetSpec
+	var namespaces *corev1.NamespaceList
+
+	for _, target := range specTargets(tfOutputs) {
+		if target.NamespaceSelector == nil {
+			targets = append(targets, target)
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(target.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespaceSelector: %w", err)
+		}
+
+		if namespaces == nil {
+			namespaces = &corev1.NamespaceList{}
+			if err := r.List(ctx, namespaces); err != nil {
+				return nil, fmt.Errorf("failed to list namespaces: %w", err)
+			}
+			sort.Slice(namespaces.Items, func(a, b int) bool {
+				return namespaces.Items[a].Name < namespaces.Items[b].Name
+			})
+		}
+
+		for _, namespace := range namespaces.Items {
+			if namespace.DeletionTimestamp != nil || !selector.Matches(labels.Set(namespace.Labels)) {
+				continue
+			}
+			selected := target
+			selected.Namespace = namespace.Name
+			selected.NamespaceSelector = nil
+			targets = append(targets, selected)
+		}
+	}
+
+	return targets, nil
+}
+
+// staleTargetResources returns the ConfigMaps and Secrets created by the TerraformOutputs that no
+// target writes anymore, such as copies in namespaces that stopped matching a namespaceSelector.
+// Labeled resources it did not create are never returned, so they are never deleted.
+func (r *TerraformOutputsReconciler) staleTargetResources(
+	ctx context.Context,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+	targets []outputsv1alpha1.TargetSpec,
+) ([]client.Object, error) {
+	configMaps := make(map[types.NamespacedName]bool, len(targets))
+	secrets := make(map[types.NamespacedName]bool, len(targets))
+	for _, target := range targets {
+		if target.ConfigMapName != "" {
+			configMaps[types.NamespacedName{Namespace: target.Namespace, Name: target.ConfigMapName}] = true
+		}
+		if target.SecretName != "" {
+			secrets[types.NamespacedName{Namespace: target.Namespace, Name: target.SecretName}] = true
+		}
+	}
+
+	selector := client.MatchingLabels{
+		sourceLabel:          tfOutputs.Name,
+		sourceNamespaceLabel: tfOutputs.Namespace,
+	}
+	var stale []client.Object
+
+	var configMapList corev1.ConfigMapList
+	if err := r.List(ctx, &configMapList, selector); err != nil {
+		return nil, fmt.Errorf("failed to list ConfigMaps: %w", err)
+	}
+	for i := range configMapList.Items {
+		configMap := &configMapList.Items[i]
+		if !configMaps[client.ObjectKeyFromObject(configMap)] && isCreatedBy(configMap, tfOutputs) {
+			stale = append(stale, configMap)
+		}
+	}
+
+	var secretList corev1.SecretList
+	if err := r.List(ctx, &secretList, selector); err != nil {
+		return nil, fmt.Errorf("failed to list Secrets: %w", err)
+	}
+	for i := range secretList.Items {
+		secret := &secretList.Items[i]
+		if !secrets[client.ObjectKeyFromObject(secret)] && isCreatedBy(secret, tfOutputs) {
+			stale = append(stale, secret)
+		}
+	}
+
+	return stale, nil
+}
+
+// deleteStaleTargetResources deletes the ConfigMaps and Secrets no target writes anymore. Without
+// targets, every ConfigMap and Secret created by the TerraformOutputs is deleted.
+func (r *TerraformOutputsReconciler) deleteStaleTargetResources(
+	ctx context.Context,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+	targets []outputsv1alpha1.TargetSpec,
+) error {
+	logger := log.FromContext(ctx)
+
+	stale, err := r.staleTargetResources(ctx, tfOutputs, targets)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range stale {
+		kind := "ConfigMap"
+		if _, ok := obj.(*corev1.Secret); ok {
+			kind = "Secret"
+		}
+		if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete stale %s %s: %w", kind, client.ObjectKeyFromObject(obj), err)
+		}
+		logger.Info(
+			"Deleted stale target resource",
+			"kind",
+			kind,
+			"name",
+			obj.GetName(),
+			"namespace",
+			obj.GetNamespace(),
+		)
+	}
+
+	return nil
+}
+
+// finalizeTargets deletes every ConfigMap and Secret created by a TerraformOutputs being deleted,
+// in any namespace, then removes its finalizer
+func (r *TerraformOutputsReconciler) finalizeTargets(
+	ctx context.Context,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+) error {
+	if !controllerutil.ContainsFinalizer(tfOutputs, targetCleanupFinalizer) {
+		return nil
+	}
+
+	if err := r.deleteStaleTargetResources(ctx, tfOutputs, nil); err != nil {
+		return err
+	}
+
+	controllerutil.RemoveFinalizer(tfOutputs, targetCleanupFinalizer)
+	if err := r.Update(ctx, tfOutputs); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove finalizer: %w", err)
+	}
+	return nil
+}
+
+// findTerraformOutputsForTargetResource maps a ConfigMap or Secret to the TerraformOutputs that
+// wrote it, by its labels rather than owner references, which cannot cross namespaces
+func (r *TerraformOutputsReconciler) findTerraformOutputsForTargetResource(
+	ctx context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	name := obj.GetLabels()[sourceLabel]
+	if name == "" {
+		return nil
+	}
+
+	// Resources written before the source-namespace label was added live next to their TerraformOutputs
+	namespace := obj.GetLabels()[sourceNamespaceLabel]
+	if namespace == "" {
+		namespace = obj.GetNamespace()
+	}
+
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: namespace, Name: name},
+	}}
+}
+
+// findTerraformOutputsForNamespace maps a Namespace to the TerraformOutputs selecting namespaces
+// by label, so new matching namespaces receive the outputs and copies in namespaces that stop
+// matching are deleted
+func (r *TerraformOutputsReconciler) findTerraformOutputsForNamespace(
+	ctx context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	var tfOutputsList outputsv1alpha1.TerraformOutputsList
+	if err := r.List(ctx, &tfOutputsList); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list TerraformOutputs for Namespace")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range tfOutputsList.Items {
+		tfOutputs := &tfOutputsList.Items[i]
+		for _, target := range specTargets(tfOutputs) {
+			if target.NamespaceSelector != nil {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(tfOutputs),
+				})
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// targetDescription describes a target in logs and status messages
+func targetDescription(target outputsv1alpha1.TargetSpec) string {
+	var names []string
+	if target.ConfigMapName != "" {
+		names = append(names, "ConfigMap "+target.ConfigMapName)
+	}
+	if target.SecretName != "" {
+		names = append(names, "Secret "+target.SecretName)
+	}
+	return fmt.Sprintf("%s in namespace %s", strings.Join(names, " and "), target.Namespace)
+}
+
+// previousTargetStatus returns the status of a target recorded by the last sync, if any
+func previousTargetStatus(
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+	target outputsv1alpha1.TargetSpec,
+) *outputsv1alpha1.TargetStatus {
+	for i, status := range tfOutputs.Status.Targets {
+		if status.Namespace == target.Namespace &&
+			status.ConfigMapName == target.ConfigMapName &&
+			status.SecretName == target.SecretName {
+			return &tfOutputs.Status.Targets[i]
+		}
+	}
+	return nil
+}
+
+// syncTargets writes the outputs to every target. Targets with their own outputs spec process
+// the fetched outputs themselves, the others get the outputs processed by spec.outputs. A
+// failing target does not stop the others: the returned statuses describe every target and the
+// returned error lists the targets that failed. The resources no target writes anymore are
+// deleted afterwards.
+func (r *TerraformOutputsReconciler) syncTargets(
+	ctx context.Context,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+	fetched *fetchedOutputs,
+	outputs map[string]interface{},
+	sensitiveFlags map[string]bool,
+) ([]outputsv1alpha1.TargetStatus, error) {
+	logger := log.FromContext(ctx)
+
+	if len(specTargets(tfOutputs)) == 0 {
+		return nil, fmt.Errorf("no targets configured")
+	}
+	targets, err := r.resolveTargets(ctx, tfOutputs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := metav1.Now()
+	statuses := make([]outputsv1alpha1.TargetStatus, 0, len(targets))
+	configMaps := make(map[types.NamespacedName]bool, len(targets))
+	secrets := make(map[types.NamespacedName]bool, len(targets))
+	var failures []string
+
+	for _, target := range targets {
+		status := outputsv1alpha1.TargetStatus{
+			Namespace:     target.Namespace,
+			ConfigMapName: target.ConfigMapName,
+			SecretName:    target.SecretName,
+		}
+
+		count, err := r.syncTarget(ctx, tfOutputs, fetched, target, outputs, sensitiveFlags, configMaps, secrets)
+		if err != nil {
+			logger.Error(err, "Failed to sync target", "target", targetDescription(target))
+			status.SyncStatus = statusFailed
+			status.Message = err.Error()
+			if previous := previousTargetStatus(tfOutputs, target); previous != nil {
+				status.OutputCount = previous.OutputCount
+				status.LastSyncTime = previous.LastSyncTime
+			}
+			failures = append(failures, fmt.Sprintf("%s: %v", targetDescription(target), err))
+		} else {
+			status.SyncStatus = "Success"
+			status.Message = fmt.Sprintf("Successfully synced %d outputs", count)
+			status.OutputCount = count
+			status.LastSyncTime = &now
+		}
+		statuses = append(statuses, status)
+	}
+
+	var syncErr error
+	if len(failures) > 0 {
+		syncErr = fmt.Errorf(
+			"failed to sync %d of %d targets: %s",
+			len(failures),
+			len(targets),
+			strings.Join(failures, "; "),
+		)
+	}
+
+	// Targets that failed are still desired, so only resources no target writes are deleted
+	if deleteErr := r.deleteStaleTargetResources(ctx, tfOutputs, targets); deleteErr != nil {
+		logger.Error(deleteErr, "Failed to delete stale target resources")
+		if syncErr == nil {
+			syncErr = deleteErr
+		}
+	}
+
+	return statuses, syncErr
+}
+
+// syncTarget writes the outputs to the ConfigMap and Secret of a single target and returns the
+// number of outputs written. configMaps and secrets record the resources written by the
+// previous targets, so two targets cannot overwrite each other.
+func (r *TerraformOutputsReconciler) syncTarget(
+	ctx context.Context,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+	fetched *fetchedOutputs,
+	target outputsv1alpha1.TargetSpec,
+	outputs map[string]interface{},
+	sensitiveFlags map[string]bool,
+	configMaps, secrets map[types.NamespacedName]bool,
+) (int, error) {
+	configMapKey := types.NamespacedName{Namespace: target.Namespace, Name: target.ConfigMapName}
+	if target.ConfigMapName != "" && configMaps[configMapKey] {
+		return 0, fmt.Errorf("another target already writes ConfigMap %s", configMapKey)
+	}
+	secretKey := types.NamespacedName{Namespace: target.Namespace, Name: target.SecretName}
+	if target.SecretName != "" && secrets[secretKey] {
+		return 0, fmt.Errorf("another target already writes Secret %s", secretKey)
+	}
+	configMaps[configMapKey] = true
+	secrets[secretKey] = true
+
+	if target.Outputs != nil {
+		var err error
+		outputs, sensitiveFlags, _, err = processOutputs(target.Outputs, fetched)
+		if err != nil {
+			return 0, fmt.Errorf("failed to process outputs: %w", err)
+		}
+	}
+
+	if err := r.syncKubernetesResources(ctx, tfOutputs, target, outputs, sensitiveFlags); err != nil {
+		return 0, fmt.Errorf("failed to sync resources: %w", err)
+	}
+	return len(outputs), nil
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
+)
+
+var _ = Describe("Targets", func() {
+	Context("When reconciling a resource with several targets", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+
+		BeforeEach(func() {
+			resource = newTestTerraformOutputs("test-targets-resource", newTestFileBackend("targets-state"))
+			resource.Spec.Targets = []outputsv1alpha1.TargetSpec{
+				{
+					Namespace:     "default",
+					ConfigMapName: "test-targets-network",
+					Outputs: &outputsv1alpha1.OutputsSpec{
+						Include: []string{"vpc_*"},
+						Transform: &outputsv1alpha1.OutputTransformSpec{
+							Case: "Upper",
+						},
+					},
+				},
+				{
+					Namespace:     "default",
+					ConfigMapName: "test-targets-invalid",
+					Outputs: &outputsv1alpha1.OutputsSpec{
+						Include: []string{"/(/"},
+					},
+				},
+			}
+			createTestObjects(ctx, newTestStateConfigMap("targets-state",
+				`{"version":4,"serial":1,"lineage":"targets-lineage","outputs":{`+
+					`"vpc_id":{"value":"vpc-123","type":"string"},`+
+					`"db_host":{"value":"db.example.com","type":"string"},`+
+					`"db_password":{"value":"hunter2","type":"string","sensitive":true}}}`,
+			), resource)
+		})
+
+		It("should sync each target independently", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).
+				To(MatchError(ContainSubstring("failed to sync 1 of 3 targets")))
+
+			By("Writing the targets that did not fail")
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("vpc_id", "vpc-123"))
+			Expect(syncedConfigMap(ctx, resource).Data).To(HaveKeyWithValue("db_host", "db.example.com"))
+			Expect(syncedSecret(ctx, resource).Data).To(HaveKeyWithValue("db_password", []byte("hunter2")))
+
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-targets-network", Namespace: "default"}, configMap)).
+				To(Succeed())
+			Expect(configMap.Data).To(Equal(map[string]string{"VPC_ID": "vpc-123"}))
+
+			By("Recording the result of each target")
+			Expect(resource.Status.SyncStatus).To(Equal(statusFailed))
+			Expect(resource.Status.Targets).To(HaveLen(3))
+			Expect(resource.Status.Targets[0].SyncStatus).To(Equal("Success"))
+			Expect(resource.Status.Targets[0].OutputCount).To(Equal(3))
+			Expect(resource.Status.Targets[1].SyncStatus).To(Equal("Success"))
+			Expect(resource.Status.Targets[1].OutputCount).To(Equal(1))
+			Expect(resource.Status.Targets[2].SyncStatus).To(Equal(statusFailed))
+			Expect(resource.Status.Targets[2].Message).To(ContainSubstring("invalid output selector"))
+			Expect(resource.Status.Targets[2].LastSyncTime).To(BeNil())
+
+			By("Fixing the failed target")
+			resource.Spec.Targets[1].Outputs.Include = []string{"db_*"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-targets-invalid", Namespace: "default"}, configMap)).
+				To(Succeed())
+			Expect(configMap.Data).To(Equal(map[string]string{"db_host": "db.example.com"}))
+
+			Expect(resource.Status.SyncStatus).To(Equal("Success"))
+			Expect(resource.Status.Targets[2].SyncStatus).To(Equal("Success"))
+			Expect(resource.Status.Targets[2].LastSyncTime).NotTo(BeNil())
+		})
+
+		It("should reject targets writing the same ConfigMap", func() {
+			statuses, err := (&TerraformOutputsReconciler{Client: k8sClient}).syncTargets(ctx, &outputsv1alpha1.TerraformOutputs{
+				Spec: outputsv1alpha1.TerraformOutputsSpec{
+					Targets: []outputsv1alpha1.TargetSpec{
+						{Namespace: "default", ConfigMapName: "test-targets-all", Outputs: &outputsv1alpha1.OutputsSpec{
+							Include: []string{"/(/"},
+						}},
+						{Namespace: "default", ConfigMapName: "test-targets-all"},
+					},
+				},
+			}, &fetchedOutputs{}, nil, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(statuses[1].Message).To(ContainSubstring("another target already writes ConfigMap default/test-targets-all"))
+		})
+	})
+
+	Context("When reconciling a resource with a namespaceSelector target", func() {
+		ctx := context.Background()
+		var resource *outputsv1alpha1.TerraformOutputs
+
+		// setShared adds or removes the label selected by the target
+		setShared := func(name string, shared bool) {
+			namespace := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name}, namespace)).To(Succeed())
+			if shared {
+				namespace.Labels = map[string]string{"tfout-test/shared": "true"}
+			} else {
+				namespace.Labels = nil
+			}
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			for _, name := range []string{"fanout-a", "fanout-b"} {
+				namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: name}, namespace); err != nil {
+					Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+				}
+			}
+			setShared("fanout-a", true)
+			setShared("fanout-b", false)
+
+			resource = newTestTerraformOutputs("test-fanout-resource", newTestFileBackend("fanout-state"))
+			resource.Spec.Target = outputsv1alpha1.TargetSpec{}
+			resource.Spec.Targets = []outputsv1alpha1.TargetSpec{
+				{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"tfout-test/shared": "true"},
+					},
+					ConfigMapName: "shared-outputs",
+					SecretName:    "shared-secrets",
+				},
+			}
+			createTestObjects(ctx, newTestStateConfigMap("fanout-state",
+				`{"version":4,"serial":1,"lineage":"fanout-lineage","outputs":{`+
+					`"cluster_endpoint":{"value":"https://k8s.example.com","type":"string"},`+
+					`"cluster_token":{"value":"hunter2","type":"string","sensitive":true}}}`,
+			), resource)
+		})
+
+		It("should replicate the outputs to the matching namespaces", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "shared-outputs", Namespace: "fanout-a"}, configMap)).
+				To(Succeed())
+			Expect(configMap.Data).To(HaveKeyWithValue("cluster_endpoint", "https://k8s.example.com"))
+			Expect(configMap.Labels).To(HaveKeyWithValue(sourceNamespaceLabel, "default"))
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: "shared-outputs", Namespace: "fanout-b"}, configMap)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("Mapping namespace events to the resource")
+			requests := controllerReconciler.findTerraformOutputsForNamespace(ctx, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "fanout-b"},
+			})
+			Expect(requests).To(ContainElement(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)}))
+
+			By("Replicating the outputs to a namespace that starts matching")
+			setShared("fanout-b", true)
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "shared-outputs", Namespace: "fanout-b"}, configMap)).
+				To(Succeed())
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "shared-secrets", Namespace: "fanout-b"}, secret)).
+				To(Succeed())
+			Expect(secret.Data).To(HaveKeyWithValue("cluster_token", []byte("hunter2")))
+
+			Expect(resource.Status.Targets).To(HaveLen(2))
+			Expect(resource.Status.Targets[1].Namespace).To(Equal("fanout-b"))
+
+			By("Deleting the copies in a namespace that stops matching")
+			setShared("fanout-a", false)
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "shared-outputs", Namespace: "fanout-a"}, configMap)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "shared-secrets", Namespace: "fanout-a"}, secret)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "shared-outputs", Namespace: "fanout-b"}, configMap)).
+				To(Succeed())
+
+			Expect(resource.Status.Targets).To(HaveLen(1))
+		})
+
+		It("should leave existing resources it did not write untouched", func() {
+			existing := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "shared-outputs",
+					Namespace: "fanout-a",
+					Labels:    map[string]string{"team": "platform"},
+				},
+				Data: map[string]string{"owner": "platform"},
+			}
+			createTestObjects(ctx, existing)
+
+			Expect(reconcileTestTerraformOutputs(ctx, newTestReconciler(), resource)).
+				To(MatchError(ContainSubstring("ConfigMap fanout-a/shared-outputs already exists and is not managed by tfout")))
+			Expect(resource.Status.Targets).To(HaveLen(1))
+			Expect(resource.Status.Targets[0].SyncStatus).To(Equal(statusFailed))
+
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(existing), configMap)).To(Succeed())
+			Expect(configMap.Data).To(Equal(map[string]string{"owner": "platform"}))
+			Expect(configMap.Labels).To(Equal(map[string]string{"team": "platform"}))
+		})
+
+		It("should adopt resources written to other namespaces before the upgrade", func() {
+			// Written by a controller that only set the source label and an owner reference
+			legacy := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "shared-outputs",
+					Namespace: "fanout-a",
+					Labels:    map[string]string{sourceLabel: resource.Name},
+					OwnerReferences: []metav1.OwnerReference{
+						*metav1.NewControllerRef(resource, outputsv1alpha1.GroupVersion.WithKind("TerraformOutputs")),
+					},
+				},
+				Data: map[string]string{"cluster_endpoint": "https://old.example.com"},
+			}
+			createTestObjects(ctx, legacy)
+
+			Expect(reconcileTestTerraformOutputs(ctx, newTestReconciler(), resource)).To(Succeed())
+
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(legacy), configMap)).To(Succeed())
+			Expect(configMap.Data).To(HaveKeyWithValue("cluster_endpoint", "https://k8s.example.com"))
+			Expect(configMap.Labels).To(HaveKeyWithValue(sourceNamespaceLabel, "default"))
+		})
+
+		It("should delete the copies in other namespaces when the resource is deleted", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(ContainElement(targetCleanupFinalizer))
+
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "shared-outputs", Namespace: "fanout-a"}, configMap)).
+				To(Succeed())
+			Expect(configMap.OwnerReferences).To(BeEmpty())
+
+			By("Mapping events of the copy to the resource by its labels")
+			requests := controllerReconciler.findTerraformOutputsForTargetResource(ctx, configMap)
+			Expect(requests).To(ConsistOf(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)}))
+
+			By("Deleting the resource")
+			deleteTerraformOutputs(ctx, resource)
+
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: "shared-outputs", Namespace: "fanout-a"}, configMap)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			secret := &corev1.Secret{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "shared-secrets", Namespace: "fanout-a"}, secret)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(resource), resource)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("When two resources write the same ConfigMap", func() {
+		ctx := context.Background()
+		var first, second *outputsv1alpha1.TerraformOutputs
+
+		BeforeEach(func() {
+			first = newTestTerraformOutputs("test-collision-first", newTestFileBackend("collision-state"))
+			second = newTestTerraformOutputs("test-collision-second", newTestFileBackend("collision-state"))
+			second.Spec.Target.ConfigMapName = first.Spec.Target.ConfigMapName
+			createTestObjects(ctx, newTestStateConfigMap("collision-state",
+				`{"version":4,"serial":1,"lineage":"collision-lineage","outputs":{`+
+					`"vpc_id":{"value":"vpc-123","type":"string"}}}`,
+			), first, second)
+		})
+
+		It("should not delete the resources of the other one when deleted", func() {
+			controllerReconciler := newTestReconciler()
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, first)).To(Succeed())
+			Expect(syncedConfigMap(ctx, first).Annotations).
+				To(HaveKeyWithValue(createdByAnnotation, "default/test-collision-first"))
+
+			Expect(reconcileTestTerraformOutputs(ctx, controllerReconciler, second)).
+				To(MatchError(ContainSubstring("is managed by TerraformOutputs default/test-collision-first")))
+			Expect(second.Finalizers).To(ContainElement(targetCleanupFinalizer))
+
+			By("Relabeling the ConfigMap as written by the second resource")
+			configMap := syncedConfigMap(ctx, first)
+			configMap.Labels = targetResourceLabels(second)
+			Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+
+			By("Deleting the second resource")
+			deleteTerraformOutputs(ctx, second)
+			Expect(syncedConfigMap(ctx, first).Data).To(HaveKeyWithValue("vpc_id", "vpc-123"))
+		})
+	})
+})
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
@@ -21,8 +24,12 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	outputsv1alpha1 "github.com/swibrow/tfout/api/v1alpha1"
 )
@@ -38,11 +45,39 @@ const (
 type TerraformOutputsReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// StateDir is the directory file backend paths are resolved in. File paths are rejected when empty.
+	StateDir string
+
+	// AllowCrossNamespaceStateSecrets lets kubernetes backends read state Secrets in another
+	// namespace than the TerraformOutputs. State Secrets are restricted to it when false.
+	AllowCrossNamespaceStateSecrets bool
+
+	// consulWatches runs blocking queries against Consul backends, set up by SetupWithManager
+	consulWatches *consulWatchManager
+
+	// s3Clients caches S3 clients per backend configuration
+	s3Clients clientCache[*s3.Client]
+
+	// azureCredentials and azureClients cache Azure identity credentials and blob clients
+	// per backend configuration
+	azureCredentials azureCredentialCache
+	azureClients     clientCache[*blob.Client]
+
+	// gcsClients caches GCS clients per backend configuration
+	gcsClients clientCache[*storage.Client]
+
+	// httpTransports shares HTTP transports per TLS configuration between the Consul and HTTP backends
+	httpTransports httpTransportCache
 }
 
 // TerraformState represents the structure of a Terraform state file
 type TerraformState struct {
-	Outputs map[string]TerraformOutput `json:"outputs"`
+	Version          int                        `json:"version"`
+	TerraformVersion string                     `json:"terraform_version"`
+	Serial           int64                      `json:"serial"`
+	Lineage          string                     `json:"lineage"`
+	Outputs          map[string]TerraformOutput `json:"outputs"`
 }
 
 // TerraformOutput represents a single output in the state
@@ -57,12 +92,50 @@ type TerraformOutput struct {
 // +kubebuilder:rbac:groups=tfout.wibrow.net,resources=terraformoutputs/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 const (
 	// ETagAnnotationPrefix stores the S3 object ETag to detect changes for each backend
 	ETagAnnotationPrefix = "terraform-tfout.wibrow.net/s3-etag-"
+
+	// GCSGenerationAnnotationPrefix stores the GCS object generation and CRC32C to detect changes for each backend
+	GCSGenerationAnnotationPrefix = "terraform-tfout.wibrow.net/gcs-generation-"
+
+	// AzureETagAnnotationPrefix stores the Azure blob ETag to detect changes for each backend
+	AzureETagAnnotationPrefix = "terraform-tfout.wibrow.net/azurerm-etag-"
+
+	// StateVersionAnnotationPrefix stores the Terraform Cloud state version ID to detect changes for each backend
+	StateVersionAnnotationPrefix = "terraform-tfout.wibrow.net/remote-state-version-"
+
+	// ResourceVersionAnnotationPrefix stores the state Secret resourceVersion to detect changes for each backend
+	ResourceVersionAnnotationPrefix = "terraform-tfout.wibrow.net/kubernetes-resource-version-"
+
+	// PGStateHashAnnotationPrefix stores the hash of the state stored in PostgreSQL to detect changes for each backend
+	PGStateHashAnnotationPrefix = "terraform-tfout.wibrow.net/pg-state-hash-"
+
+	// ModifyIndexAnnotationPrefix stores the Consul KV ModifyIndex to detect changes for each backend
+	ModifyIndexAnnotationPrefix = "terraform-tfout.wibrow.net/consul-modify-index-"
+
+	// HTTPValidatorAnnotationPrefix stores the ETag or Last-Modified validator of an HTTP state to detect changes
+	HTTPValidatorAnnotationPrefix = "terraform-tfout.wibrow.net/http-validator-"
+
+	// FileHashAnnotationPrefix stores the content hash of a file backend state to detect changes
+	FileHashAnnotationPrefix = "terraform-tfout.wibrow.net/file-sha256-"
 )
 
+// backendVersionAnnotationPrefixes are the prefixes of the annotations storing backend versions
+var backendVersionAnnotationPrefixes = []string{
+	ETagAnnotationPrefix,
+	GCSGenerationAnnotationPrefix,
+	AzureETagAnnotationPrefix,
+	StateVersionAnnotationPrefix,
+	ResourceVersionAnnotationPrefix,
+	PGStateHashAnnotationPrefix,
+	ModifyIndexAnnotationPrefix,
+	HTTPValidatorAnnotationPrefix,
+	FileHashAnnotationPrefix,
+}
+
 var (
 	// Metrics for monitoring the operator performance and behavior
 	reconcileTotal = prometheus.NewCounterVec(
@@ -87,7 +160,7 @@ var (
 			Name: "terraform_outputs_backend_fetch_total",
 			Help: "Total number of backend fetches",
 		},
-		[]string{"namespace", "name", "backend_type", "backend_index", "result"},
+		[]string{"namespace", "name", "backend_type", "backend", "result"},
 	)
 
 	backendFetchDuration = prometheus.NewHistogramVec(
@@ -96,7 +169,7 @@ var (
 			Help:    "Duration of backend fetch operations",
 			Buckets: prometheus.DefBuckets,
 		},
-		[]string{"namespace", "name", "backend_type", "backend_index"},
+		[]string{"namespace", "name", "backend_type", "backend"},
 	)
 
 	outputsFound = prometheus.NewGaugeVec(
@@ -115,6 +188,22 @@ var (
 		[]string{"namespace", "name"},
 	)
 
+	filteredOutputs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "terraform_outputs_filtered_total",
+			Help: "Total number of outputs dropped by the include/exclude filters",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	outputConflicts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "terraform_outputs_conflicts_total",
+			Help: "Total number of outputs exported with different values by several backends",
+		},
+		[]string{"namespace", "name"},
+	)
+
 	lastSyncTimestamp = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "terraform_outputs_last_sync_timestamp",
@@ -131,6 +220,30 @@ var (
 		[]string{"namespace", "name", "operation", "result"},
 	)
 
+	clientCacheRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "terraform_outputs_backend_client_cache_requests_total",
+			Help: "Total number of backend client cache lookups",
+		},
+		[]string{"backend_type", "result"},
+	)
+
+	clientCacheSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "terraform_outputs_backend_client_cache_size",
+			Help: "Number of cached backend clients",
+		},
+		[]string{"backend_type"},
+	)
+
+	backendRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "terraform_outputs_backend_requests_total",
+			Help: "Total number of requests made to non-S3 state backends",
+		},
+		[]string{"namespace", "name", "backend_type", "operation", "result"},
+	)
+
 	configMapOperationsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "terraform_outputs_configmap_operations_total",
@@ -157,8 +270,13 @@ func init() {
 		backendFetchDuration,
 		outputsFound,
 		sensitiveOutputsFound,
+		filteredOutputs,
+		outputConflicts,
 		lastSyncTimestamp,
 		s3RequestsTotal,
+		clientCacheRequestsTotal,
+		clientCacheSize,
+		backendRequestsTotal,
 		configMapOperationsTotal,
 		secretOperationsTotal,
 	)
@@ -172,6 +290,9 @@ func (r *TerraformOutputsReconciler) Reconcile(
 	startTime := time.Now()
 	logger := log.FromContext(ctx)
 
+	// Download every HTTP state at most once per reconcile
+	ctx = withHTTPStateCache(ctx)
+
 	// Track reconcile metrics
 	labels := prometheus.Labels{
 		"namespace": req.Namespace,
@@ -185,6 +306,8 @@ func (r *TerraformOutputsReconciler) Reconcile(
 			logger.Info(
 				"TerraformOutputs resource not found. Ignoring since object must be deleted",
 			)
+			// Stop any Consul blocking queries of the deleted resource
+			r.consulWatches.sync(req.NamespacedName, nil, nil, nil)
 			// Record successful reconcile for deleted resource
 			labels["result"] = resultSuccess
 			reconcileTotal.With(labels).Inc()
@@ -198,6 +321,57 @@ func (r *TerraformOutputsReconciler) Reconcile(
 		return ctrl.Result{}, err
 	}
 
+	// Delete the ConfigMaps and Secrets of every target before letting the resource go
+	if !terraformOutputs.DeletionTimestamp.IsZero() {
+		r.consulWatches.sync(req.NamespacedName, nil, nil, nil)
+		if err := r.finalizeTargets(ctx, &terraformOutputs); err != nil {
+			logger.Error(err, "Failed to clean up target resources")
+			labels["result"] = resultError
+			reconcileTotal.With(labels).Inc()
+			reconcileDuration.With(labels).Observe(time.Since(startTime).Seconds())
+			return ctrl.Result{}, err
+		}
+		labels["result"] = resultSuccess
+		reconcileTotal.With(labels).Inc()
+		reconcileDuration.With(labels).Observe(time.Since(startTime).Seconds())
+		return ctrl.Result{}, nil
+	}
+
+	if controllerutil.AddFinalizer(&terraformOutputs, targetCleanupFinalizer) {
+		if err := r.Update(ctx, &terraformOutputs); err != nil {
+			logger.Error(err, "Failed to add finalizer")
+			labels["result"] = resultError
+			reconcileTotal.With(labels).Inc()
+			reconcileDuration.With(labels).Observe(time.Since(startTime).Seconds())
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Name the backends without a name. The names are never written back, so the spec stays
+	// as applied by the user.
+	defaultBackendNames(&terraformOutputs)
+	if err := validateBackendNames(&terraformOutputs); err != nil {
+		logger.Error(err, "Invalid backends")
+		if statusErr := r.updateStatusWithRetry(
+			ctx,
+			req.NamespacedName,
+			func(tfOutputs *outputsv1alpha1.TerraformOutputs) {
+				tfOutputs.Status.SyncStatus = statusFailed
+				tfOutputs.Status.Message = fmt.Sprintf("Invalid backends: %v", err)
+			},
+		); statusErr != nil {
+			logger.Error(statusErr, "Failed to update status")
+		}
+		labels["result"] = resultError
+		reconcileTotal.With(labels).Inc()
+		reconcileDuration.With(labels).Observe(time.Since(startTime).Seconds())
+		// Retrying does not help, the resource is reconciled again when its spec changes
+		return ctrl.Result{}, nil
+	}
+
+	// Make sure Consul backends are watched with blocking queries
+	r.syncConsulWatches(ctx, &terraformOutputs)
+
 	// Parse sync interval
 	syncInterval, err := time.ParseDuration(terraformOutputs.Spec.SyncInterval)
 	if err != nil {
@@ -208,9 +382,15 @@ func (r *TerraformOutputsReconciler) Reconcile(
 	// If so, we need to recreate them regardless of sync interval or ETag
 	shouldForceSync := r.shouldForceSyncDueToMissingResources(ctx, &terraformOutputs)
 
-	if !shouldForceSync {
-		// Check if we need to sync based on last sync time
-		if terraformOutputs.Status.LastSyncTime != nil {
+	// A spec change (outputs filters, mappings, targets, ...) changes what is synced even when
+	// no backend state changed, so it is applied regardless of sync interval or ETag too
+	specChanged := terraformOutputs.Status.ObservedGeneration != terraformOutputs.Generation
+
+	if !shouldForceSync && !specChanged {
+		// Check if we need to sync based on last sync time. Watched backends (state Secrets)
+		// trigger a reconcile on change, so those bypass the sync interval.
+		if terraformOutputs.Status.LastSyncTime != nil &&
+			!r.hasWatchedBackendChanges(ctx, &terraformOutputs) {
 			timeSinceLastSync := time.Since(terraformOutputs.Status.LastSyncTime.Time)
 			if timeSinceLastSync < syncInterval {
 				logger.Info(
@@ -224,8 +404,12 @@ func (r *TerraformOutputsReconciler) Reconcile(
 			}
 		}
 
-		// Check if any S3 objects have changed by comparing ETags
+		// Check if any backend state has changed by comparing ETags/versions
 		hasChanges, _, err := r.checkBackendChanges(ctx, &terraformOutputs)
+		if lockedErr := asHTTPStateLockedError(err); lockedErr != nil {
+			logger.Info("Terraform state is locked, deferring sync", "error", lockedErr.Error())
+			return ctrl.Result{RequeueAfter: httpStateLockedRetryInterval}, nil
+		}
 		if err != nil {
 			logger.Error(err, "Failed to check backend changes")
 			// Update status to Failed with retry
@@ -252,8 +436,10 @@ func (r *TerraformOutputsReconciler) Reconcile(
 		}
 
 		logger.Info("Backend changes detected, processing updates")
-	} else {
+	} else if shouldForceSync {
 		logger.Info("Force sync triggered due to missing ConfigMap/Secret resources")
+	} else {
+		logger.Info("Spec changed since the last sync, processing updates")
 	}
 
 	// Update status to InProgress with retry
@@ -270,7 +456,20 @@ func (r *TerraformOutputsReconciler) Reconcile(
 	}
 
 	// Fetch outputs from all backends
-	outputs, sensitiveFlags, err := r.fetchAllTerraformOutputs(ctx, &terraformOutputs)
+	fetched, err := r.fetchAllTerraformOutputs(ctx, &terraformOutputs)
+	if lockedErr := asHTTPStateLockedError(err); lockedErr != nil {
+		logger.Info("Terraform state is locked, deferring sync", "error", lockedErr.Error())
+		if statusErr := r.updateStatusWithRetry(
+			ctx,
+			req.NamespacedName,
+			func(tfOutputs *outputsv1alpha1.TerraformOutputs) {
+				tfOutputs.Status.Message = fmt.Sprintf("Waiting for the state lock to be released: %v", lockedErr)
+			},
+		); statusErr != nil {
+			logger.Error(statusErr, "Failed to update status")
+		}
+		return ctrl.Result{RequeueAfter: httpStateLockedRetryInterval}, nil
+	}
 	if err != nil {
 		logger.Error(err, "Failed to fetch Terraform outputs")
 		// Update status to Failed with retry
@@ -280,6 +479,12 @@ func (r *TerraformOutputsReconciler) Reconcile(
 			func(tfOutputs *outputsv1alpha1.TerraformOutputs) {
 				tfOutputs.Status.SyncStatus = statusFailed
 				tfOutputs.Status.Message = fmt.Sprintf("Failed to fetch outputs: %v", err)
+				if asStateDecryptionError(err) != nil {
+					setStateDecryptedCondition(tfOutputs, err)
+				}
+				if conflictErr := asOutputConflictError(err); conflictErr != nil {
+					setOutputConflictsCondition(tfOutputs, conflictErr.conflicts, err)
+				}
 			},
 		); statusErr != nil {
 			logger.Error(statusErr, "Failed to update status")
@@ -290,16 +495,67 @@ func (r *TerraformOutputsReconciler) Reconcile(
 		return ctrl.Result{RequeueAfter: syncInterval}, err
 	}
 
-	// Create/Update ConfigMaps and Secrets
-	if err := r.syncKubernetesResources(ctx, &terraformOutputs, outputs, sensitiveFlags); err != nil {
+	// Filter, rename and transform the outputs as configured in spec.outputs
+	outputs, sensitiveFlags, filteredCount, err := processOutputs(terraformOutputs.Spec.Outputs, fetched)
+	if err != nil {
+		logger.Error(err, "Failed to process Terraform outputs")
+		if statusErr := r.updateStatusWithRetry(
+			ctx,
+			req.NamespacedName,
+			func(tfOutputs *outputsv1alpha1.TerraformOutputs) {
+				tfOutputs.Status.SyncStatus = statusFailed
+				tfOutputs.Status.Message = fmt.Sprintf("Failed to process outputs: %v", err)
+			},
+		); statusErr != nil {
+			logger.Error(statusErr, "Failed to update status")
+		}
+		labels["result"] = resultError
+		reconcileTotal.With(labels).Inc()
+		reconcileDuration.With(labels).Observe(time.Since(startTime).Seconds())
+		return ctrl.Result{RequeueAfter: syncInterval}, err
+	}
+
+	// With Serial or ContentHash change detection, a new object version does not mean the
+	// state changed. Only record the new versions, so the state is not downloaded again.
+	if !shouldForceSync && !stateChanged(&terraformOutputs, fetched.backendStatuses) {
+		logger.Info(
+			"Backend versions changed but the Terraform state did not, skipping sync",
+			"changeDetection",
+			terraformOutputs.Spec.ChangeDetection,
+		)
+		if err := r.updateResourceWithRetry(ctx, req.NamespacedName, func(tfOutputs *outputsv1alpha1.TerraformOutputs) {
+			tfOutputs.Status.SyncStatus = "Success"
+			tfOutputs.Status.Message = "Terraform state unchanged"
+			if _, currentETags, err := r.checkBackendChanges(ctx, withDefaultBackendNames(tfOutputs)); err == nil {
+				if tfOutputs.Annotations == nil {
+					tfOutputs.Annotations = make(map[string]string)
+				}
+				r.updateETagAnnotations(tfOutputs, currentETags)
+			}
+		}); err != nil {
+			logger.Error(err, "Failed to update status and annotations")
+			return ctrl.Result{}, err
+		}
+
+		labels["result"] = resultSuccess
+		reconcileTotal.With(labels).Inc()
+		reconcileDuration.With(labels).Observe(time.Since(startTime).Seconds())
+		return ctrl.Result{RequeueAfter: syncInterval}, nil
+	}
+
+	// Create/Update the ConfigMaps and Secrets of every target
+	targetStatuses, err := r.syncTargets(ctx, &terraformOutputs, fetched, outputs, sensitiveFlags)
+	if err != nil {
 		logger.Error(err, "Failed to sync Kubernetes resources")
-		// Update status to Failed with retry
+		// Update status to Failed with retry. The backend versions are not recorded, so the
+		// failed targets are retried on the next reconcile.
 		if statusErr := r.updateStatusWithRetry(
 			ctx,
 			req.NamespacedName,
 			func(tfOutputs *outputsv1alpha1.TerraformOutputs) {
 				tfOutputs.Status.SyncStatus = statusFailed
 				tfOutputs.Status.Message = fmt.Sprintf("Failed to sync resources: %v", err)
+				tfOutputs.Status.Targets = targetStatuses
 			},
 		); statusErr != nil {
 			logger.Error(statusErr, "Failed to update status")
@@ -310,28 +566,33 @@ func (r *TerraformOutputsReconciler) Reconcile(
 		return ctrl.Result{RequeueAfter: syncInterval}, err
 	}
 
-	// Update both status and ETag annotation with retry (only update ETag if not force sync)
+	// Update both status and ETag annotation with retry
 	if err := r.updateResourceWithRetry(ctx, req.NamespacedName, func(tfOutputs *outputsv1alpha1.TerraformOutputs) {
 		// Update status
 		now := metav1.Now()
 		tfOutputs.Status.LastSyncTime = &now
 		tfOutputs.Status.SyncStatus = "Success"
 		tfOutputs.Status.OutputCount = len(outputs)
+		tfOutputs.Status.FilteredOutputCount = filteredCount
+		tfOutputs.Status.ObservedGeneration = tfOutputs.Generation
+		tfOutputs.Status.Backends = fetched.backendStatuses
+		tfOutputs.Status.Targets = targetStatuses
+		setStateDecryptedCondition(tfOutputs, nil)
+		setOutputConflictsCondition(tfOutputs, fetched.conflicts, nil)
 		if shouldForceSync {
 			tfOutputs.Status.Message = fmt.Sprintf("Successfully recreated missing resources with %d outputs", len(outputs))
 		} else {
 			tfOutputs.Status.Message = fmt.Sprintf("Successfully synced %d outputs", len(outputs))
 		}
 
-		// Update ETag annotations only if this wasn't a force sync
-		if !shouldForceSync {
-			// Get current ETags again (might have changed during processing)
-			if _, currentETags, err := r.checkBackendChanges(ctx, tfOutputs); err == nil {
-				if tfOutputs.Annotations == nil {
-					tfOutputs.Annotations = make(map[string]string)
-				}
-				r.updateETagAnnotations(tfOutputs, currentETags)
+		// Update ETag annotations, also after a force sync: it fetched every backend, so
+		// recording the versions spares downloading the states again on the next check.
+		// Get current ETags again (might have changed during processing)
+		if _, currentETags, err := r.checkBackendChanges(ctx, withDefaultBackendNames(tfOutputs)); err == nil {
+			if tfOutputs.Annotations == nil {
+				tfOutputs.Annotations = make(map[string]string)
 			}
+			r.updateETagAnnotations(tfOutputs, currentETags)
 		}
 	}); err != nil {
 		logger.Error(err, "Failed to update status and annotations")
@@ -347,6 +608,12 @@ func (r *TerraformOutputsReconciler) Reconcile(
 	outputsFound.With(prometheus.Labels{"namespace": req.Namespace, "name": req.Name}).
 		Set(float64(len(outputs)))
 
+	filteredOutputs.With(prometheus.Labels{"namespace": req.Namespace, "name": req.Name}).
+		Set(float64(filteredCount))
+
+	outputConflicts.With(prometheus.Labels{"namespace": req.Namespace, "name": req.Name}).
+		Set(float64(len(fetched.conflicts)))
+
 	sensitiveCount := 0
 	for _, isSensitive := range sensitiveFlags {
 		if isSensitive {
@@ -369,62 +636,100 @@ func (r *TerraformOutputsReconciler) Reconcile(
 	return ctrl.Result{RequeueAfter: syncInterval}, nil
 }
 
-// shouldForceSyncDueToMissingResources checks if ConfigMap or Secret are missing and need recreation
+// shouldForceSyncDueToMissingResources checks if the ConfigMap or Secret of any target are missing
+// and need recreation, or if resources no target writes anymore need to be deleted
 func (r *TerraformOutputsReconciler) shouldForceSyncDueToMissingResources(
 	ctx context.Context,
 	tfOutputs *outputsv1alpha1.TerraformOutputs,
 ) bool {
 	logger := log.FromContext(ctx)
 
+	targets, err := r.resolveTargets(ctx, tfOutputs)
+	if err != nil {
+		logger.Error(err, "Failed to resolve targets")
+		return false
+	}
+
+	for _, target := range targets {
+		if r.targetNeedsSync(ctx, tfOutputs, target) {
+			return true
+		}
+	}
+
+	// Copies in namespaces that stopped matching a namespaceSelector are deleted by a sync
+	stale, err := r.staleTargetResources(ctx, tfOutputs, targets)
+	if err != nil {
+		logger.Error(err, "Failed to check for stale target resources")
+	} else if len(stale) > 0 {
+		logger.Info("Stale target resources found, triggering force sync", "resources", len(stale))
+		return true
+	}
+
+	return false
+}
+
+// targetNeedsSync checks if the ConfigMap or Secret of a target are missing or not labeled as
+// written by the TerraformOutputs
+func (r *TerraformOutputsReconciler) targetNeedsSync(
+	ctx context.Context,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+	target outputsv1alpha1.TargetSpec,
+) bool {
+	logger := log.FromContext(ctx)
+
 	// Check if ConfigMap should exist but is missing
-	if tfOutputs.Spec.Target.ConfigMapName != "" {
+	if target.ConfigMapName != "" {
 		configMap := &corev1.ConfigMap{}
 		err := r.Get(ctx, types.NamespacedName{
-			Name:      tfOutputs.Spec.Target.ConfigMapName,
-			Namespace: tfOutputs.Spec.Target.Namespace,
+			Name:      target.ConfigMapName,
+			Namespace: target.Namespace,
 		}, configMap)
 
 		if errors.IsNotFound(err) {
 			logger.Info(
 				"ConfigMap missing, triggering force sync",
 				"configmap",
-				tfOutputs.Spec.Target.ConfigMapName,
+				target.ConfigMapName,
+				"namespace",
+				target.Namespace,
 			)
 			return true
 		} else if err != nil {
 			logger.Error(err, "Failed to check ConfigMap existence")
 		} else {
-			// Check if ConfigMap has proper owner reference
-			if !r.hasOwnerReference(configMap.GetOwnerReferences(), tfOutputs) {
-				logger.Info("ConfigMap exists but lacks proper owner reference, triggering force sync",
-					"configmap", tfOutputs.Spec.Target.ConfigMapName)
+			// Check if ConfigMap carries the labels tracking its TerraformOutputs
+			if !isTargetResourceOf(configMap, tfOutputs) || configMap.Labels[sourceNamespaceLabel] == "" {
+				logger.Info("ConfigMap exists but lacks the source labels, triggering force sync",
+					"configmap", target.ConfigMapName, "namespace", target.Namespace)
 				return true
 			}
 		}
 	}
 
 	// Check if Secret should exist but is missing
-	if tfOutputs.Spec.Target.SecretName != "" {
+	if target.SecretName != "" {
 		secret := &corev1.Secret{}
 		err := r.Get(ctx, types.NamespacedName{
-			Name:      tfOutputs.Spec.Target.SecretName,
-			Namespace: tfOutputs.Spec.Target.Namespace,
+			Name:      target.SecretName,
+			Namespace: target.Namespace,
 		}, secret)
 
 		if errors.IsNotFound(err) {
 			logger.Info(
 				"Secret missing, triggering force sync",
 				"secret",
-				tfOutputs.Spec.Target.SecretName,
+				target.SecretName,
+				"namespace",
+				target.Namespace,
 			)
 			return true
 		} else if err != nil {
 			logger.Error(err, "Failed to check Secret existence")
 		} else {
-			// Check if Secret has proper owner reference
-			if !r.hasOwnerReference(secret.GetOwnerReferences(), tfOutputs) {
-				logger.Info("Secret exists but lacks proper owner reference, triggering force sync",
-					"secret", tfOutputs.Spec.Target.SecretName)
+			// Check if Secret carries the labels tracking its TerraformOutputs
+			if !isTargetResourceOf(secret, tfOutputs) || secret.Labels[sourceNamespaceLabel] == "" {
+				logger.Info("Secret exists but lacks the source labels, triggering force sync",
+					"secret", target.SecretName, "namespace", target.Namespace)
 				return true
 			}
 		}
@@ -433,59 +738,113 @@ func (r *TerraformOutputsReconciler) shouldForceSyncDueToMissingResources(
 	return false
 }
 
-// hasOwnerReference checks if the given owner references include our TerraformOutputs resource
-func (r *TerraformOutputsReconciler) hasOwnerReference(
-	ownerRefs []metav1.OwnerReference,
-	tfOutputs *outputsv1alpha1.TerraformOutputs,
-) bool {
-	for _, ref := range ownerRefs {
-		if ref.Kind == "TerraformOutputs" &&
-			ref.APIVersion == "tfout.wibrow.net/v1alpha1" &&
-			ref.Name == tfOutputs.Name &&
-			ref.UID == tfOutputs.UID {
-			return true
-		}
-	}
-	return false
-}
-
-// checkBackendChanges checks if any backend has changed by comparing ETags
+// checkBackendChanges checks if any backend has changed by comparing the stored version
+// annotations (ETag, generation, ...) with the current backend versions.
+// The returned map is keyed by annotation name.
 func (r *TerraformOutputsReconciler) checkBackendChanges(
 	ctx context.Context,
 	tfOutputs *outputsv1alpha1.TerraformOutputs,
-) (bool, map[int]string, error) {
+) (bool, map[string]string, error) {
 	if len(tfOutputs.Spec.Backends) == 0 {
 		return false, nil, fmt.Errorf("no backends configured")
 	}
 
-	currentETags := make(map[int]string)
+	currentVersions := make(map[string]string)
 	hasChanges := false
 
-	for i, backend := range tfOutputs.Spec.Backends {
-		backendType := backend.GetBackendType()
-		if backendType != "s3" {
-			return false, nil, fmt.Errorf("unsupported backend type: %s", backendType)
+	for _, backend := range tfOutputs.Spec.Backends {
+		var annotation, version string
+		var err error
+
+		switch backend.GetBackendType() {
+		case "s3":
+			annotation = ETagAnnotationPrefix + backend.Name
+			version, err = r.getS3ObjectETag(ctx, *backend.S3, tfOutputs.Namespace, tfOutputs.Name)
+		case "gcs":
+			annotation = GCSGenerationAnnotationPrefix + backend.Name
+			version, err = r.getGCSObjectGeneration(ctx, *backend.GCS, tfOutputs.Namespace, tfOutputs.Name)
+		case "azurerm":
+			annotation = AzureETagAnnotationPrefix + backend.Name
+			version, err = r.getAzureBlobETag(ctx, *backend.AzureRM, tfOutputs.Namespace, tfOutputs.Name)
+		case "remote":
+			annotation = StateVersionAnnotationPrefix + backend.Name
+			version, err = r.getRemoteStateVersion(ctx, *backend.Remote, tfOutputs.Namespace, tfOutputs.Name)
+		case "kubernetes":
+			annotation = ResourceVersionAnnotationPrefix + backend.Name
+			version, err = r.getStateSecretResourceVersion(ctx, *backend.Kubernetes, tfOutputs.Namespace)
+		case "pg":
+			annotation = PGStateHashAnnotationPrefix + backend.Name
+			version, err = r.getPGStateHash(ctx, *backend.PG, tfOutputs.Namespace, tfOutputs.Name)
+		case "consul":
+			annotation = ModifyIndexAnnotationPrefix + backend.Name
+			version, err = r.getConsulModifyIndex(ctx, *backend.Consul, tfOutputs.Namespace, tfOutputs.Name)
+		case "http":
+			annotation = HTTPValidatorAnnotationPrefix + backend.Name
+			version, err = r.getHTTPStateValidator(
+				ctx,
+				*backend.HTTP,
+				tfOutputs.Annotations[annotation],
+				tfOutputs.Namespace,
+				tfOutputs.Name,
+			)
+		case "file":
+			annotation = FileHashAnnotationPrefix + backend.Name
+			version, err = r.getFileStateHash(ctx, *backend.File, tfOutputs.Namespace)
+		default:
+			return false, nil, fmt.Errorf("unsupported backend type: %s", backend.GetBackendType())
 		}
-
-		etag, err := r.getS3ObjectETag(ctx, *backend.S3, tfOutputs.Namespace, tfOutputs.Name)
 		if err != nil {
-			return false, nil, fmt.Errorf("failed to get ETag for backend %d: %w", i, err)
+			return false, nil, fmt.Errorf("failed to get version for backend %s: %w", backend.Name, err)
 		}
 
-		currentETags[i] = etag
+		currentVersions[annotation] = version
 
-		// Compare with stored ETag
-		storedETag := ""
+		// Compare with stored version
+		storedVersion := ""
 		if tfOutputs.Annotations != nil {
-			storedETag = tfOutputs.Annotations[fmt.Sprintf("%s%d", ETagAnnotationPrefix, i)]
+			storedVersion = tfOutputs.Annotations[annotation]
 		}
 
-		if storedETag == "" || storedETag != etag {
+		if storedVersion == "" || storedVersion != version {
 			hasChanges = true
 		}
 	}
 
-	return hasChanges, currentETags, nil
+	return hasChanges, currentVersions, nil
+}
+
+// hasWatchedBackendChanges checks whether any watched backend (state Secrets, Consul blocking
+// queries) has changed since the last sync. It only reads from the informer cache and the
+// Consul watches, so it is cheap to call on every reconcile.
+func (r *TerraformOutputsReconciler) hasWatchedBackendChanges(
+	ctx context.Context,
+	tfOutputs *outputsv1alpha1.TerraformOutputs,
+) bool {
+	for _, backend := range tfOutputs.Spec.Backends {
+		if backend.Consul != nil {
+			modifyIndex, ok := r.consulWatches.modifyIndex(client.ObjectKeyFromObject(tfOutputs), backend.Name)
+			annotation := ModifyIndexAnnotationPrefix + backend.Name
+			if ok && tfOutputs.Annotations[annotation] != strconv.FormatUint(modifyIndex, 10) {
+				return true
+			}
+			continue
+		}
+
+		if backend.Kubernetes == nil {
+			continue
+		}
+
+		version, err := r.getStateSecretResourceVersion(ctx, *backend.Kubernetes, tfOutputs.Namespace)
+		if err != nil {
+			continue
+		}
+
+		if tfOutputs.Annotations[ResourceVersionAnnotationPrefix+backend.Name] != version {
+			return true
+		}
+	}
+
+	return false
 }
 
 // getS3ObjectETag gets the ETag of an S3 object without downloading it
@@ -494,21 +853,22 @@ func (r *TerraformOutputsReconciler) getS3ObjectETag(
 	s3Spec outputsv1alpha1.S3Spec,
 	namespace, name string,
 ) (string, error) {
-	// Load AWS configuration
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(s3Spec.Region))
+	s3Client, err := r.newS3Client(ctx, s3Spec, namespace, name)
 	if err != nil {
-		return "", fmt.Errorf("failed to load AWS config: %w", err)
+		return "", err
 	}
 
-	// Create S3 client with optional custom endpoint
-	var s3Client *s3.Client
-	if s3Spec.Endpoint != "" {
-		s3Client = s3.NewFromConfig(cfg, func(o *s3.Options) {
-			o.BaseEndpoint = aws.String(s3Spec.Endpoint)
-			o.UsePathStyle = true // Often needed for S3-compatible services
-		})
-	} else {
-		s3Client = s3.NewFromConfig(cfg)
+	if s3Spec.AllWorkspaces || s3Spec.KeyPattern != "" {
+		states, err := r.listS3States(ctx, s3Client, s3Spec, namespace, name)
+		if err != nil {
+			return "", err
+		}
+		return s3StatesVersion(states), nil
+	}
+
+	stateKey, err := s3StateKey(s3Spec)
+	if err != nil {
+		return "", err
 	}
 
 	// Use HeadObject to get metadata without downloading the file
@@ -520,7 +880,7 @@ func (r *TerraformOutputsReconciler) getS3ObjectETag(
 
 	result, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s3Spec.Bucket),
-		Key:    aws.String(s3Spec.Key),
+		Key:    aws.String(stateKey),
 	})
 	if err != nil {
 		s3Labels["result"] = resultError
@@ -538,13 +898,68 @@ func (r *TerraformOutputsReconciler) getS3ObjectETag(
 	return etag, nil
 }
 
-// updateETagAnnotations updates the ETag annotations for all backends
+// defaultBackendNames names the backends without a name <type>-<index>, and moves their version
+// annotations from the index, used before backends had a name, to the new name, so upgrading does
+// not resync them. Only the in-memory object is changed: the names are computed on every
+// reconcile, and the version annotations are moved for good by the next sync.
+func defaultBackendNames(tfOutputs *outputsv1alpha1.TerraformOutputs) {
+	for i := range tfOutputs.Spec.Backends {
+		backend := &tfOutputs.Spec.Backends[i]
+		if backend.Name != "" {
+			continue
+		}
+		backend.Name = fmt.Sprintf("%s-%d", backend.GetBackendType(), i)
+
+		for _, prefix := range backendVersionAnnotationPrefixes {
+			legacyAnnotation := fmt.Sprintf("%s%d", prefix, i)
+			if version, ok := tfOutputs.Annotations[legacyAnnotation]; ok {
+				tfOutputs.Annotations[prefix+backend.Name] = version
+				delete(tfOutputs.Annotations, legacyAnnotation)
+			}
+		}
+	}
+}
+
+// withDefaultBackendNames returns a copy of the TerraformOutputs with default backend names, for
+// reading a freshly fetched resource without writing the names back
+func withDefaultBackendNames(tfOutputs *outputsv1alpha1.TerraformOutputs) *outputsv1alpha1.TerraformOutputs {
+	named := tfOutputs.DeepCopy()
+	defaultBackendNames(named)
+	return named
+}
+
+// validateBackendNames returns an error if several backends have the same name
+func validateBackendNames(tfOutputs *outputsv1alpha1.TerraformOutputs) error {
+	names := make(map[string]bool, len(tfOutputs.Spec.Backends))
+	for _, backend := range tfOutputs.Spec.Backends {
+		if names[backend.Name] {
+			return fmt.Errorf("backend name %q is used by several backends", backend.Name)
+		}
+		names[backend.Name] = true
+	}
+	return nil
+}
+
+// updateETagAnnotations updates the version annotations for all backends and removes the
+// annotations of backends that were renamed or removed
 func (r *TerraformOutputsReconciler) updateETagAnnotations(
 	tfOutputs *outputsv1alpha1.TerraformOutputs,
-	etags map[int]string,
+	versions map[string]string,
 ) {
-	for i, etag := range etags {
-		tfOutputs.Annotations[fmt.Sprintf("%s%d", ETagAnnotationPrefix, i)] = etag
+	for annotation := range tfOutputs.Annotations {
+		if _, ok := versions[annotation]; ok {
+			continue
+		}
+		for _, prefix := range backendVersionAnnotationPrefixes {
+			if strings.HasPrefix(annotation, prefix) {
+				delete(tfOutputs.Annotations, annotation)
+				break
+			}
+		}
+	}
+
+	for annotation, version := range versions {
+		tfOutputs.Annotations[annotation] = version
 	}
 }
 
@@ -564,12 +979,15 @@ func (r *TerraformOutputsReconciler) updateResourceWithRetry(
 		// Apply the update function
 		updateFunc(&terraformOutputs)
 
-		// Update the resource (this will update both annotations and status)
+		// Update the resource annotations. The API server ignores status here and returns
+		// the stored status, so keep a copy for the status subresource update.
+		status := terraformOutputs.Status.DeepCopy()
 		if err := r.Update(ctx, &terraformOutputs); err != nil {
 			return err
 		}
 
 		// Also update the status subresource
+		terraformOutputs.Status = *status
 		return r.Status().Update(ctx, &terraformOutputs)
 	})
 }
@@ -594,62 +1012,171 @@ func (r *TerraformOutputsReconciler) updateStatusWithRetry(
 	})
 }
 
-// fetchAllTerraformOutputs fetches outputs from all backends and merges them
+// fetchedOutputs holds the outputs fetched from all backends
+type fetchedOutputs struct {
+	// outputs and sensitiveFlags are the merged outputs of all backends
+	outputs        map[string]interface{}
+	sensitiveFlags map[string]bool
+
+	// backendNames, backendOutputs and backendSensitiveFlags are the names and the outputs of
+	// each backend, in the order of spec.backends
+	backendNames          []string
+	backendOutputs        []map[string]interface{}
+	backendSensitiveFlags []map[string]bool
+
+	// backendStatuses describes the state files read from each backend
+	backendStatuses []outputsv1alpha1.BackendStatus
+
+	// conflicts lists the outputs exported with different values by several backends
+	conflicts []string
+}
+
+// fetchAllTerraformOutputs fetches outputs from all backends and merges them. It also
+// returns the outputs and the metadata of the state files of each backend.
 func (r *TerraformOutputsReconciler) fetchAllTerraformOutputs(
 	ctx context.Context,
 	tfOutputs *outputsv1alpha1.TerraformOutputs,
-) (map[string]interface{}, map[string]bool, error) {
+) (*fetchedOutputs, error) {
 	logger := log.FromContext(ctx)
 
 	if len(tfOutputs.Spec.Backends) == 0 {
-		return nil, nil, fmt.Errorf("no backends configured")
+		return nil, fmt.Errorf("no backends configured")
 	}
 
-	// Merged outputs from all backends
-	mergedOutputs := make(map[string]interface{})
-	mergedSensitiveFlags := make(map[string]bool)
+	fetched := &fetchedOutputs{
+		outputs:        make(map[string]interface{}),
+		sensitiveFlags: make(map[string]bool),
+	}
 
-	for i, backend := range tfOutputs.Spec.Backends {
+	for _, backend := range tfOutputs.Spec.Backends {
 		backendType := backend.GetBackendType()
-		if backendType != "s3" {
-			return nil, nil, fmt.Errorf(
-				"unsupported backend type: %s for backend %d",
-				backendType,
-				i,
-			)
-		}
 
-		logger.Info(
-			"Processing backend",
-			"index",
-			i,
-			"bucket",
-			backend.S3.Bucket,
-			"key",
-			backend.S3.Key,
-		)
+		logger.Info("Processing backend", "backend", backend.Name, "type", backendType)
 
 		// Track backend fetch metrics
 		backendStartTime := time.Now()
 		backendLabels := prometheus.Labels{
-			"namespace":     tfOutputs.Namespace,
-			"name":          tfOutputs.Name,
-			"backend_type":  backendType,
-			"backend_index": fmt.Sprintf("%d", i),
+			"namespace":    tfOutputs.Namespace,
+			"name":         tfOutputs.Name,
+			"backend_type": backendType,
+			"backend":      backend.Name,
 		}
 
-		outputs, sensitiveFlags, err := r.fetchTerraformOutputsFromS3(
-			ctx,
-			*backend.S3,
-			i,
-			tfOutputs.Namespace,
-			tfOutputs.Name,
-		)
+		// Encrypted state is decrypted while parsing, with the key material carried in the context
+		decryption, err := r.resolveStateDecryption(ctx, backend.Encryption, tfOutputs.Namespace)
+		if err != nil {
+			backendLabels["result"] = resultError
+			backendFetchTotal.With(backendLabels).Inc()
+			return nil, fmt.Errorf(
+				"failed to fetch outputs from backend %s: %w",
+				backend.Name,
+				&stateDecryptionError{message: err.Error()},
+			)
+		}
+		recorder := &stateRecorder{}
+		backendCtx := withStateRecorder(withStateDecryption(ctx, decryption), recorder)
+
+		var outputs map[string]interface{}
+		var sensitiveFlags map[string]bool
+
+		switch backendType {
+		case "s3":
+			outputs, sensitiveFlags, err = r.fetchTerraformOutputsFromS3(
+				backendCtx,
+				*backend.S3,
+				backend.Name,
+				tfOutputs.Namespace,
+				tfOutputs.Name,
+			)
+		case "gcs":
+			outputs, sensitiveFlags, err = r.fetchTerraformOutputsFromGCS(
+				backendCtx,
+				*backend.GCS,
+				backend.Name,
+				tfOutputs.Namespace,
+				tfOutputs.Name,
+			)
+		case "azurerm":
+			outputs, sensitiveFlags, err = r.fetchTerraformOutputsFromAzure(
+				backendCtx,
+				*backend.AzureRM,
+				backend.Name,
+				tfOutputs.Namespace,
+				tfOutputs.Name,
+			)
+		case "remote":
+			outputs, sensitiveFlags, err = r.fetchTerraformOutputsFromRemote(
+				backendCtx,
+				*backend.Remote,
+				backend.Name,
+				tfOutputs.Namespace,
+				tfOutputs.Name,
+			)
+		case "kubernetes":
+			outputs, sensitiveFlags, err = r.fetchTerraformOutputsFromKubernetes(
+				backendCtx,
+				*backend.Kubernetes,
+				backend.Name,
+				tfOutputs.Namespace,
+			)
+		case "pg":
+			outputs, sensitiveFlags, err = r.fetchTerraformOutputsFromPG(
+				backendCtx,
+				*backend.PG,
+				backend.Name,
+				tfOutputs.Namespace,
+				tfOutputs.Name,
+			)
+		case "consul":
+			outputs, sensitiveFlags, err = r.fetchTerraformOutputsFromConsul(
+				backendCtx,
+				*backend.Consul,
+				backend.Name,
+				tfOutputs.Namespace,
+				tfOutputs.Name,
+			)
+		case "http":
+			outputs, sensitiveFlags, err = r.fetchTerraformOutputsFromHTTP(
+				backendCtx,
+				*backend.HTTP,
+				backend.Name,
+				tfOutputs.Namespace,
+				tfOutputs.Name,
+			)
+		case "file":
+			outputs, sensitiveFlags, err = r.fetchTerraformOutputsFromFile(
+				backendCtx,
+				*backend.File,
+				backend.Name,
+				tfOutputs.Namespace,
+			)
+		default:
+			return nil, fmt.Errorf(
+				"unsupported backend type: %s for backend %s",
+				backendType,
+				backend.Name,
+			)
+		}
+
+		if err == nil {
+			// Backends reading several state files parse them in no particular order
+			sort.SliceStable(recorder.states, func(a, b int) bool {
+				return recorder.states[a].State < recorder.states[b].State
+			})
+			for _, state := range recorder.states {
+				state.Name = backend.Name
+				state.Type = backendType
+				if err = checkLineage(tfOutputs, state); err != nil {
+					break
+				}
+				fetched.backendStatuses = append(fetched.backendStatuses, state)
+			}
+		}
 
 		if err != nil {
 			backendLabels["result"] = resultError
 			backendFetchTotal.With(backendLabels).Inc()
-			return nil, nil, fmt.Errorf("failed to fetch outputs from backend %d: %w", i, err)
+			return nil, fmt.Errorf("failed to fetch outputs from backend %s: %w", backend.Name, err)
 		}
 
 		backendLabels["result"] = resultSuccess
@@ -659,73 +1186,96 @@ func (r *TerraformOutputsReconciler) fetchAllTerraformOutputs(
 		delete(backendLabels, "result")
 		backendFetchDuration.With(backendLabels).Observe(time.Since(backendStartTime).Seconds())
 
-		// Merge outputs, checking for conflicts
-		for key, value := range outputs {
-			if existingValue, exists := mergedOutputs[key]; exists {
-				logger.Info(
-					"Output key conflict detected, using latest value",
-					"key",
-					key,
-					"backend",
-					i,
-				)
-				// Log the conflict but use the latest value (last backend wins)
-				_ = existingValue
-			}
-			mergedOutputs[key] = value
-			mergedSensitiveFlags[key] = sensitiveFlags[key]
-		}
+		outputs, sensitiveFlags = prefixOutputKeys(backend.KeyPrefix, outputs, sensitiveFlags)
+		fetched.backendNames = append(fetched.backendNames, backend.Name)
+		fetched.backendOutputs = append(fetched.backendOutputs, outputs)
+		fetched.backendSensitiveFlags = append(fetched.backendSensitiveFlags, sensitiveFlags)
 
-		logger.Info("Successfully processed backend", "index", i, "outputs", len(outputs))
+		logger.Info("Successfully processed backend", "backend", backend.Name, "outputs", len(outputs))
 	}
 
+	// Merge outputs according to the merge strategy, recording conflicts
+	conflicts, err := mergeBackendOutputs(tfOutputs.Spec.MergeStrategy, fetched)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		logger.Info(
+			"Outputs exported with different values by several backends",
+			"conflicts",
+			conflicts,
+			"mergeStrategy",
+			tfOutputs.Spec.MergeStrategy,
+		)
+	}
+	fetched.conflicts = conflicts
+
 	logger.Info(
 		"Successfully fetched and merged Terraform outputs from all backends",
 		"totalOutputs",
-		len(mergedOutputs),
+		len(fetched.outputs),
 		"backends",
 		len(tfOutputs.Spec.Backends),
 	)
-	return mergedOutputs, mergedSensitiveFlags, nil
+
+	return fetched, nil
 }
 
 // fetchTerraformOutputsFromS3 fetches outputs from a single S3 backend
 func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromS3(
 	ctx context.Context,
 	s3Spec outputsv1alpha1.S3Spec,
-	backendIndex int,
+	backendName string,
 	namespace, name string,
 ) (map[string]interface{}, map[string]bool, error) {
 	logger := log.FromContext(ctx)
 
-	// Load AWS configuration
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(s3Spec.Region))
+	s3Client, err := r.newS3Client(ctx, s3Spec, namespace, name)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return nil, nil, err
 	}
 
-	// Create S3 client with optional custom endpoint
-	var s3Client *s3.Client
-	if s3Spec.Endpoint != "" {
-		s3Client = s3.NewFromConfig(cfg, func(o *s3.Options) {
-			o.BaseEndpoint = aws.String(s3Spec.Endpoint)
-			o.UsePathStyle = true // Often needed for S3-compatible services
-		})
-	} else {
-		s3Client = s3.NewFromConfig(cfg)
+	if s3Spec.AllWorkspaces || s3Spec.KeyPattern != "" {
+		states, err := r.listS3States(ctx, s3Client, s3Spec, namespace, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r.fetchTerraformOutputsFromS3States(ctx, s3Client, s3Spec, states, backendName, namespace, name)
+	}
+
+	stateKey, err := s3StateKey(s3Spec)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Download state file
 	logger.Info(
 		"Downloading Terraform state",
 		"backend",
-		backendIndex,
+		backendName,
 		"bucket",
 		s3Spec.Bucket,
 		"key",
-		s3Spec.Key,
+		stateKey,
 	)
 
+	body, err := r.downloadS3State(ctx, s3Client, s3Spec.Bucket, stateKey, namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parseTerraformOutputs(ctx, body)
+}
+
+// downloadS3State downloads a single state file from S3
+func (r *TerraformOutputsReconciler) downloadS3State(
+	ctx context.Context,
+	s3Client *s3.Client,
+	bucket, key string,
+	namespace, name string,
+) ([]byte, error) {
+	logger := log.FromContext(ctx)
+
 	s3Labels := prometheus.Labels{
 		"namespace": namespace,
 		"name":      name,
@@ -733,13 +1283,13 @@ func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromS3(
 	}
 
 	result, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s3Spec.Bucket),
-		Key:    aws.String(s3Spec.Key),
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
 	})
 	if err != nil {
 		s3Labels["result"] = resultError
 		s3RequestsTotal.With(s3Labels).Inc()
-		return nil, nil, fmt.Errorf("failed to download state file: %w", err)
+		return nil, fmt.Errorf("failed to download state file: %w", err)
 	}
 	defer func() {
 		if err := result.Body.Close(); err != nil {
@@ -753,14 +1303,30 @@ func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromS3(
 	// Read the entire body
 	body, err := io.ReadAll(result.Body)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read state file body: %w", err)
+		return nil, fmt.Errorf("failed to read state file body: %w", err)
+	}
+
+	return body, nil
+}
+
+// parseTerraformOutputs parses a raw Terraform state file and extracts
+// the output values and their sensitivity flags. OpenTofu encrypted state is
+// decrypted first with the key material carried in ctx, and the state metadata
+// is recorded in the stateRecorder of ctx.
+func parseTerraformOutputs(ctx context.Context, body []byte) (map[string]interface{}, map[string]bool, error) {
+	body, err := decryptState(ctx, body)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Parse Terraform state
 	var tfState TerraformState
 	if err := json.Unmarshal(body, &tfState); err != nil {
 		return nil, nil, fmt.Errorf("failed to parse Terraform state: %w", err)
 	}
+	if err := validateStateVersion(tfState.Version); err != nil {
+		return nil, nil, err
+	}
+	recordState(ctx, tfState, body)
 
 	// Extract output values and sensitivity flags
 	outputs := make(map[string]interface{})
@@ -774,10 +1340,30 @@ func (r *TerraformOutputsReconciler) fetchTerraformOutputsFromS3(
 	return outputs, sensitiveFlags, nil
 }
 
+// readSecretKey reads a single key from a Secret in the given namespace
+func (r *TerraformOutputsReconciler) readSecretKey(
+	ctx context.Context,
+	namespace string,
+	ref outputsv1alpha1.SecretKeyReference,
+) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get Secret %s/%s: %w", namespace, ref.Name, err)
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in Secret %s/%s", ref.Key, namespace, ref.Name)
+	}
+
+	return value, nil
+}
+
 // syncKubernetesResources creates/updates ConfigMaps and Secrets based on sensitivity flags
 func (r *TerraformOutputsReconciler) syncKubernetesResources(
 	ctx context.Context,
 	tfOutputs *outputsv1alpha1.TerraformOutputs,
+	target outputsv1alpha1.TargetSpec,
 	outputs map[string]interface{},
 	sensitiveFlags map[string]bool,
 ) error {
@@ -824,54 +1410,62 @@ func (r *TerraformOutputsReconciler) syncKubernetesResources(
 	)
 
 	// Create/Update ConfigMap if needed and has non-sensitive data
-	if tfOutputs.Spec.Target.ConfigMapName != "" && len(configData) > 0 {
-		if err := r.syncConfigMap(ctx, tfOutputs, configData); err != nil {
+	if target.ConfigMapName != "" && len(configData) > 0 {
+		if err := r.syncConfigMap(ctx, tfOutputs, target, configData); err != nil {
 			return fmt.Errorf("failed to sync ConfigMap: %w", err)
 		}
 		logger.Info(
 			"ConfigMap synced",
 			"name",
-			tfOutputs.Spec.Target.ConfigMapName,
+			target.ConfigMapName,
+			"namespace",
+			target.Namespace,
 			"keys",
 			len(configData),
 		)
 	}
 
 	// Create/Update Secret if needed and has sensitive data
-	if tfOutputs.Spec.Target.SecretName != "" && len(secretData) > 0 {
-		if err := r.syncSecret(ctx, tfOutputs, secretData); err != nil {
+	if target.SecretName != "" && len(secretData) > 0 {
+		if err := r.syncSecret(ctx, tfOutputs, target, secretData); err != nil {
 			return fmt.Errorf("failed to sync Secret: %w", err)
 		}
 		logger.Info(
 			"Secret synced",
 			"name",
-			tfOutputs.Spec.Target.SecretName,
+			target.SecretName,
+			"namespace",
+			target.Namespace,
 			"keys",
 			len(secretData),
 		)
 	}
 
 	// If ConfigMap is specified but no non-sensitive data exists, create empty ConfigMap
-	if tfOutputs.Spec.Target.ConfigMapName != "" && len(configData) == 0 {
-		if err := r.syncConfigMap(ctx, tfOutputs, configData); err != nil {
+	if target.ConfigMapName != "" && len(configData) == 0 {
+		if err := r.syncConfigMap(ctx, tfOutputs, target, configData); err != nil {
 			return fmt.Errorf("failed to sync empty ConfigMap: %w", err)
 		}
 		logger.Info(
 			"Empty ConfigMap synced (no non-sensitive outputs)",
 			"name",
-			tfOutputs.Spec.Target.ConfigMapName,
+			target.ConfigMapName,
+			"namespace",
+			target.Namespace,
 		)
 	}
 
 	// If Secret is specified but no sensitive data exists, create empty Secret
-	if tfOutputs.Spec.Target.SecretName != "" && len(secretData) == 0 {
-		if err := r.syncSecret(ctx, tfOutputs, secretData); err != nil {
+	if target.SecretName != "" && len(secretData) == 0 {
+		if err := r.syncSecret(ctx, tfOutputs, target, secretData); err != nil {
 			return fmt.Errorf("failed to sync empty Secret: %w", err)
 		}
 		logger.Info(
 			"Empty Secret synced (no sensitive outputs)",
 			"name",
-			tfOutputs.Spec.Target.SecretName,
+			target.SecretName,
+			"namespace",
+			target.Namespace,
 		)
 	}
 
@@ -882,23 +1476,25 @@ func (r *TerraformOutputsReconciler) syncKubernetesResources(
 func (r *TerraformOutputsReconciler) syncConfigMap(
 	ctx context.Context,
 	tfOutputs *outputsv1alpha1.TerraformOutputs,
+	target outputsv1alpha1.TargetSpec,
 	data map[string]string,
 ) error {
 	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      tfOutputs.Spec.Target.ConfigMapName,
-			Namespace: tfOutputs.Spec.Target.Namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/managed-by": "tfout",
-				"terraform-outputs/source":     tfOutputs.Name,
-			},
+			Name:        target.ConfigMapName,
+			Namespace:   target.Namespace,
+			Labels:      targetResourceLabels(tfOutputs),
+			Annotations: targetResourceAnnotations(tfOutputs),
 		},
 		Data: data,
 	}
 
-	// Set owner reference
-	if err := ctrl.SetControllerReference(tfOutputs, configMap, r.Scheme); err != nil {
-		return err
+	// Set owner reference. Owner references cannot cross namespaces, so resources in other
+	// namespaces are only tracked by their labels and deleted by the finalizer.
+	if target.Namespace == tfOutputs.Namespace {
+		if err := ctrl.SetControllerReference(tfOutputs, configMap, r.Scheme); err != nil {
+			return err
+		}
 	}
 
 	// Create or update
@@ -927,9 +1523,14 @@ func (r *TerraformOutputsReconciler) syncConfigMap(
 		return err
 	}
 
+	// Never take over a ConfigMap written by someone else
+	if err := checkTargetResourceOwnership("ConfigMap", existingConfigMap, tfOutputs); err != nil {
+		return err
+	}
+
 	// Update existing ConfigMap
 	existingConfigMap.Data = data
-	existingConfigMap.Labels = configMap.Labels
+	mergeTargetResourceLabels(existingConfigMap, tfOutputs)
 	configMapLabels["operation"] = "update"
 	err = r.Update(ctx, existingConfigMap)
 	if err != nil {
@@ -945,24 +1546,26 @@ func (r *TerraformOutputsReconciler) syncConfigMap(
 func (r *TerraformOutputsReconciler) syncSecret(
 	ctx context.Context,
 	tfOutputs *outputsv1alpha1.TerraformOutputs,
+	target outputsv1alpha1.TargetSpec,
 	data map[string][]byte,
 ) error {
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      tfOutputs.Spec.Target.SecretName,
-			Namespace: tfOutputs.Spec.Target.Namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/managed-by": "tfout",
-				"terraform-outputs/source":     tfOutputs.Name,
-			},
+			Name:        target.SecretName,
+			Namespace:   target.Namespace,
+			Labels:      targetResourceLabels(tfOutputs),
+			Annotations: targetResourceAnnotations(tfOutputs),
 		},
 		Data: data,
 		Type: corev1.SecretTypeOpaque,
 	}
 
-	// Set owner reference
-	if err := ctrl.SetControllerReference(tfOutputs, secret, r.Scheme); err != nil {
-		return err
+	// Set owner reference. Owner references cannot cross namespaces, so resources in other
+	// namespaces are only tracked by their labels and deleted by the finalizer.
+	if target.Namespace == tfOutputs.Namespace {
+		if err := ctrl.SetControllerReference(tfOutputs, secret, r.Scheme); err != nil {
+			return err
+		}
 	}
 
 	// Create or update
@@ -991,9 +1594,14 @@ func (r *TerraformOutputsReconciler) syncSecret(
 		return err
 	}
 
+	// Never take over a Secret written by someone else
+	if err := checkTargetResourceOwnership("Secret", existingSecret, tfOutputs); err != nil {
+		return err
+	}
+
 	// Update existing Secret
 	existingSecret.Data = data
-	existingSecret.Labels = secret.Labels
+	mergeTargetResourceLabels(existingSecret, tfOutputs)
 	secretLabels["operation"] = "update"
 	err = r.Update(ctx, existingSecret)
 	if err != nil {
@@ -1005,12 +1613,40 @@ func (r *TerraformOutputsReconciler) syncSecret(
 	return err
 }
 
+// findTerraformOutputsForSecret maps a Secret to the TerraformOutputs that wrote it or read
+// their state from it, so Secrets are watched with a single event handler
+func (r *TerraformOutputsReconciler) findTerraformOutputsForSecret(
+	ctx context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	return append(
+		r.findTerraformOutputsForTargetResource(ctx, obj),
+		r.findTerraformOutputsForStateSecret(ctx, obj)...,
+	)
+}
+
 // SetupWithManager sets up the controller with the Manager
 func (r *TerraformOutputsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.consulWatches = newConsulWatchManager()
+	if err := mgr.Add(r.consulWatches); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&outputsv1alpha1.TerraformOutputs{}).
-		Owns(&corev1.ConfigMap{}).
-		Owns(&corev1.Secret{}).
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.findTerraformOutputsForTargetResource),
+		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findTerraformOutputsForSecret),
+		).
+		Watches(
+			&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.findTerraformOutputsForNamespace),
+		).
+		WatchesRawSource(source.Channel(r.consulWatches.events, &handler.EnqueueRequestForObject{})).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: 1, // Ensure serial processing to avoid conflicts
 		}).
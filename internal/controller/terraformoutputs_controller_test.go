@@ -102,6 +102,7 @@ var _ = Describe("TerraformOutputs Controller", func() {
 					SyncInterval: "5m",
 					Backends: []outputsv1alpha1.BackendSpec{
 						{
+							Name: "s3",
 							S3: &outputsv1alpha1.S3Spec{
 								Bucket:   "test-bucket",
 								Key:      "test.tfstate",
@@ -136,7 +137,7 @@ var _ = Describe("TerraformOutputs Controller", func() {
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
 			if err == nil {
 				By("Cleanup the specific resource instance TerraformOutputs")
-				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+				deleteTerraformOutputs(ctx, resource)
 			}
 
 			// Clean up created ConfigMap and Secret
@@ -237,6 +238,12 @@ var _ = Describe("TerraformOutputs Controller", func() {
 			}, configMap)).To(Succeed())
 			Expect(k8sClient.Delete(ctx, configMap)).To(Succeed())
 
+			By("Forgetting the recorded backend version")
+			resource := &outputsv1alpha1.TerraformOutputs{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			delete(resource.Annotations, ETagAnnotationPrefix+"s3")
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
 			By("Reconciling again should recreate the ConfigMap")
 			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
@@ -250,6 +257,59 @@ var _ = Describe("TerraformOutputs Controller", func() {
 				Namespace: "default",
 			}, newConfigMap)).To(Succeed())
 			Expect(newConfigMap.Data).To(HaveKey("vpc_id"))
+
+			By("Recording the backend version after the force sync")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Annotations).To(HaveKey(ETagAnnotationPrefix + "s3"))
+		})
+	})
+
+	Context("When upgrading a resource created before backends had a name", func() {
+		It("should name the backends and keep their version annotations", func() {
+			tfOutputs := newTestTerraformOutputs("test-legacy-resource",
+				outputsv1alpha1.BackendSpec{S3: &outputsv1alpha1.S3Spec{Bucket: "state", Key: "app.tfstate"}},
+				outputsv1alpha1.BackendSpec{Consul: &outputsv1alpha1.ConsulSpec{Path: "app"}},
+			)
+			tfOutputs.Annotations = map[string]string{
+				ETagAnnotationPrefix + "0":        `"etag-v1"`,
+				ModifyIndexAnnotationPrefix + "1": "42",
+			}
+
+			defaultBackendNames(tfOutputs)
+			Expect(tfOutputs.Spec.Backends[0].Name).To(Equal("s3-0"))
+			Expect(tfOutputs.Spec.Backends[1].Name).To(Equal("consul-1"))
+			Expect(tfOutputs.Annotations).To(Equal(map[string]string{
+				ETagAnnotationPrefix + "s3-0":            `"etag-v1"`,
+				ModifyIndexAnnotationPrefix + "consul-1": "42",
+			}))
+		})
+
+		It("should not write the default names to the spec", func() {
+			ctx := context.Background()
+			backend := newTestFileBackend("legacy-state")
+			backend.Name = ""
+			resource := newTestTerraformOutputs("test-legacy-reconcile", backend)
+			resource.Annotations = map[string]string{FileHashAnnotationPrefix + "0": "stale"}
+			createTestObjects(ctx, newTestStateConfigMap("legacy-state", `{"version":4,"outputs":{}}`), resource)
+
+			reconciler := &TerraformOutputsReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			key := types.NamespacedName{Name: resource.Name, Namespace: resource.Namespace}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, resource)).To(Succeed())
+			Expect(resource.Spec.Backends[0].Name).To(BeEmpty())
+			Expect(resource.Annotations).NotTo(HaveKey(FileHashAnnotationPrefix + "0"))
+			Expect(resource.Annotations).To(HaveKey(FileHashAnnotationPrefix + "file-0"))
+		})
+
+		It("should reject backends with the same name", func() {
+			tfOutputs := newTestTerraformOutputs("test-duplicate-names",
+				outputsv1alpha1.BackendSpec{S3: &outputsv1alpha1.S3Spec{Bucket: "state", Key: "app.tfstate"}},
+				outputsv1alpha1.BackendSpec{Name: "s3-0", S3: &outputsv1alpha1.S3Spec{Bucket: "state", Key: "db.tfstate"}},
+			)
+			defaultBackendNames(tfOutputs)
+			Expect(validateBackendNames(tfOutputs)).To(MatchError(ContainSubstring(`"s3-0"`)))
 		})
 	})
 })